@@ -0,0 +1,133 @@
+package appmanager
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+// Columnar files are a minimal Parquet-like format for large exports:
+//
+//	magic "RLCOL" + version byte
+//	uint32 length + JSON array of exportColumn (the schema)
+//	row groups: uint32 row count, then every column's values for those rows
+//	uint32 0 (end of file)
+//
+// All integers are little-endian, float32 and float64 are IEEE 754, int is int64,
+// bool is one byte and string is uint32 length + UTF-8 bytes
+const (
+	columnarMagic        = "RLCOL"
+	columnarVersion      = 1
+	columnarRowGroupSize = 8192
+)
+
+// Types of exported columns
+const (
+	columnFloat32 = "float32"
+	columnFloat64 = "float64"
+	columnInt     = "int"
+	columnBool    = "bool"
+	columnString  = "string"
+)
+
+// exportColumn is a single column of an export schema, values are float32, float64, int, bool or string
+type exportColumn struct {
+	Name string
+	Type string
+}
+
+// tableWriter streams rows that follow a fixed schema to a file
+type tableWriter interface {
+	write(row []interface{}) error
+	close() error
+}
+
+// columnarWriter buffers rows into row groups and writes them column by column
+type columnarWriter struct {
+	file    *os.File
+	writer  *bufio.Writer
+	columns []exportColumn
+	rows    [][]interface{}
+}
+
+func newColumnarWriter(path string, columns []exportColumn) (*columnarWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	cw := &columnarWriter{
+		file:    file,
+		writer:  bufio.NewWriter(file),
+		columns: columns,
+		rows:    make([][]interface{}, 0, columnarRowGroupSize),
+	}
+	schema, _ := json.Marshal(columns)
+	cw.writer.WriteString(columnarMagic)
+	cw.writer.WriteByte(columnarVersion)
+	binary.Write(cw.writer, binary.LittleEndian, uint32(len(schema)))
+	cw.writer.Write(schema)
+	return cw, nil
+}
+
+func (cw *columnarWriter) write(row []interface{}) error {
+	cw.rows = append(cw.rows, row)
+	if len(cw.rows) == columnarRowGroupSize {
+		return cw.flushRowGroup()
+	}
+	return nil
+}
+
+func (cw *columnarWriter) flushRowGroup() error {
+	if len(cw.rows) == 0 {
+		return nil
+	}
+	var buf [8]byte
+	binary.LittleEndian.PutUint32(buf[:4], uint32(len(cw.rows)))
+	cw.writer.Write(buf[:4])
+	for i, column := range cw.columns {
+		for _, row := range cw.rows {
+			switch column.Type {
+			case columnFloat32:
+				binary.LittleEndian.PutUint32(buf[:4], math.Float32bits(row[i].(float32)))
+				cw.writer.Write(buf[:4])
+			case columnFloat64:
+				binary.LittleEndian.PutUint64(buf[:], math.Float64bits(row[i].(float64)))
+				cw.writer.Write(buf[:])
+			case columnInt:
+				binary.LittleEndian.PutUint64(buf[:], uint64(int64(row[i].(int))))
+				cw.writer.Write(buf[:])
+			case columnBool:
+				if row[i].(bool) {
+					cw.writer.WriteByte(1)
+				} else {
+					cw.writer.WriteByte(0)
+				}
+			case columnString:
+				value := row[i].(string)
+				binary.LittleEndian.PutUint32(buf[:4], uint32(len(value)))
+				cw.writer.Write(buf[:4])
+				cw.writer.WriteString(value)
+			default:
+				return fmt.Errorf("column %v has unknown type %v", column.Name, column.Type)
+			}
+		}
+	}
+	cw.rows = cw.rows[:0]
+	return nil
+}
+
+func (cw *columnarWriter) close() error {
+	if err := cw.flushRowGroup(); err != nil {
+		cw.file.Close()
+		return err
+	}
+	binary.Write(cw.writer, binary.LittleEndian, uint32(0))
+	if err := cw.writer.Flush(); err != nil {
+		cw.file.Close()
+		return err
+	}
+	return cw.file.Close()
+}
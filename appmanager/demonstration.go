@@ -0,0 +1,67 @@
+package appmanager
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/renatobrittoaraujo/rl/input"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// demonstrationsDirName is the directory next to the landing log that holds one
+// demonstration per user flight, named after the flight's telemetry ID
+const demonstrationsDirName = "demonstrations"
+
+var demonstrationsDir = filepath.Join("logs", demonstrationsDirName)
+
+// DemonstrationsDir returns where user flights logged to logPath are saved as demonstrations
+func DemonstrationsDir(logPath string) string {
+	return filepath.Join(filepath.Dir(logPath), demonstrationsDirName)
+}
+
+// demonstrationRecorder passes observations to its manager and keeps the last one,
+// so it can be paired with the action the episode applied
+type demonstrationRecorder struct {
+	input.Manager
+	demonstration *input.Demonstration
+	observation   sim.Observation
+	acted         bool
+}
+
+// newDemonstrationRecorder returns a recorder of flights of manager, nil for inputs that are not the user
+func newDemonstrationRecorder(manager input.Manager, inputType, seed, level int) *demonstrationRecorder {
+	if inputType != input.UserInput {
+		return nil
+	}
+	return &demonstrationRecorder{Manager: manager, demonstration: input.NewDemonstration(inputType, seed, level)}
+}
+
+func (dr *demonstrationRecorder) Act(obs sim.Observation) sim.Action {
+	dr.observation, dr.acted = obs, true
+	return dr.Manager.Act(obs)
+}
+
+// record appends the last observation and the action applied on it, ticks without one (ascension) are skipped
+func (dr *demonstrationRecorder) record(action sim.Action) {
+	if dr == nil || !dr.acted {
+		return
+	}
+	dr.demonstration.Steps = append(dr.demonstration.Steps, input.DemonstrationStep{Observation: dr.observation, Action: action})
+	dr.acted = false
+}
+
+// save writes the demonstration of a finished flight to demonstrationsDir, named id
+func (dr *demonstrationRecorder) save(rocket *sim.Rocket, outcome sim.Outcome, id string) {
+	if dr == nil || len(dr.demonstration.Steps) == 0 {
+		return
+	}
+	if id == "" {
+		id = newTelemetryID()
+	}
+	dr.demonstration.Outcome = outcome.String()
+	dr.demonstration.Score = sim.LandingScore(rocket)
+	if err := dr.demonstration.Save(filepath.Join(demonstrationsDir, id+".jsonl")); err != nil {
+		fmt.Println("Could not save demonstration of flight", id)
+		fmt.Println(err.Error())
+	}
+}
@@ -0,0 +1,284 @@
+package appmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/renatobrittoaraujo/rl/helpers"
+	"github.com/renatobrittoaraujo/rl/input"
+	"github.com/renatobrittoaraujo/rl/runs"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// EvalOptions configure an evaluation of a controller over a seed suite
+type EvalOptions struct {
+	Suite          string    // name of the seed suite (see SuiteNames)
+	Workers        int       // flights simulated in parallel, 0 uses every CPU
+	ReportPath     string    // where the JSON report is written, empty skips it
+	BaselinePath   string    // report to compare against, empty skips the comparison
+	RateTolerance  float64   // how much success rate may drop against the baseline before it is a regression
+	ScoreTolerance float64   // how much median score may drop against the baseline before it is a regression
+	Telemetry      bool      // records telemetry of every flight
+	LogLandings    bool      // logs every flight to the landing log
+	Run            *runs.Run // records the seeds and summary of the evaluation, nil records nothing
+}
+
+// evalReport is the machine readable result of an evaluation
+type evalReport struct {
+	Controller    string
+	Suite         string
+	Level         int
+	Limits        sim.Limits
+	Scorer        string // sim.Scorer name of every score
+	ScorerVersion int
+	ScorerWeights string
+	Flights       int
+	Successes     int
+	SuccessRate   float64
+	Score         scoreDistribution
+	Outcomes      map[string]int
+	Results       []evalResult
+}
+
+type scoreDistribution struct {
+	Mean float64
+	Min  float64
+	P10  float64
+	P25  float64
+	P50  float64
+	P75  float64
+	P90  float64
+	Max  float64
+}
+
+// evalResult is how a single flight of the suite ended
+type evalResult struct {
+	Seed    int
+	Score   float32 // sim.FlightScore
+	Outcome string
+	Ticks   int
+}
+
+// StartEvaluation flies opts' controller over every seed of eval.Suite (or its first opts.Episodes seeds)
+// on CLI, as fast as possible, prints a summary and writes a report
+//
+// Returns true if there is a baseline and the controller regressed against it
+func StartEvaluation(opts Options, eval EvalOptions) (regressed bool, err error) {
+	seeds, err := seedSuite(eval.Suite)
+	if err != nil {
+		return false, err
+	}
+	if opts.Episodes > 0 && opts.Episodes < len(seeds) {
+		seeds = seeds[:opts.Episodes]
+	}
+	if opts.InputType == input.UserInput {
+		return false, fmt.Errorf("user input can not be evaluated on CLI")
+	}
+	var baseline *evalReport
+	if eval.BaselinePath != "" {
+		baseline = &evalReport{}
+		if err := readReport(eval.BaselinePath, baseline); err != nil {
+			return false, err
+		}
+		if baseline.Suite != eval.Suite || baseline.Level != opts.Level || baseline.Flights != len(seeds) {
+			return false, fmt.Errorf("baseline was evaluated on %v flights of suite %v at level %v, not on %v flights of suite %v at level %v",
+				baseline.Flights, baseline.Suite, baseline.Level, len(seeds), eval.Suite, opts.Level)
+		}
+		if baseline.Limits != opts.Limits {
+			return false, fmt.Errorf("baseline was evaluated with limits %+v, not %+v", baseline.Limits, opts.Limits)
+		}
+		// Reports from before scorers were logged all used logistic version 1
+		if baseline.Scorer != "" && (baseline.Scorer != sim.DefaultScorer.Name() || baseline.ScorerVersion != sim.DefaultScorer.Version() ||
+			baseline.ScorerWeights != sim.DefaultScorer.Weights()) {
+			return false, fmt.Errorf("baseline was scored by %v version %v with weights %v, not by %v version %v with weights %v",
+				baseline.Scorer, baseline.ScorerVersion, baseline.ScorerWeights, sim.DefaultScorer.Name(), sim.DefaultScorer.Version(), sim.DefaultScorer.Weights())
+		}
+	}
+	if eval.LogLandings {
+		openLogs(opts.LogPath)
+	} else {
+		telemetryDir = filepath.Join(filepath.Dir(opts.LogPath), telemetryDirName)
+	}
+
+	if err := eval.Run.Flew(seeds); err != nil {
+		return false, err
+	}
+	results, err := evaluateSeeds(opts, eval, seeds)
+	if err != nil {
+		return false, err
+	}
+	report := newEvalReport(opts, eval.Suite, results)
+	report.print()
+	if err := eval.Run.Evaluated(report.Score.P50, report.SuccessRate); err != nil {
+		return false, err
+	}
+	if eval.ReportPath != "" {
+		if err := writeReport(eval.ReportPath, report); err != nil {
+			return false, err
+		}
+		fmt.Println("Report written to", eval.ReportPath)
+	}
+	if baseline != nil {
+		return report.compare(*baseline, eval.RateTolerance, eval.ScoreTolerance), nil
+	}
+	return false, nil
+}
+
+// evaluateSeeds flies one flight per seed, spread over eval.Workers workers
+func evaluateSeeds(opts Options, eval EvalOptions, seeds []int) ([]evalResult, error) {
+	workers := eval.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]evalResult, len(seeds))
+	errs := make([]error, len(seeds))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], errs[i] = evaluateSeed(opts, eval, seeds[i])
+			}
+		}()
+	}
+	for i := range seeds {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// evaluateSeed flies seed with a fresh input, so no state of an earlier flight (plans, sampling noise) changes it
+func evaluateSeed(opts Options, eval EvalOptions, seed int) (evalResult, error) {
+	manager, inputErr := input.CreateInput(opts.InputType)
+	if inputErr {
+		return evalResult{}, fmt.Errorf("input %v has not been initalized correctly", input.InputString[opts.InputType])
+	}
+	episode := sim.NewEpisode(seed, opts.Level)
+	episode.Limits = opts.Limits
+	var recorder *flightRecorder
+	if eval.Telemetry {
+		recorder = newFlightRecorder(seed, 0, opts.InputType, opts.Level, opts.Limits)
+	}
+	for {
+		action, done := episode.Step(manager)
+		recorder.record(episode.Rocket, action)
+		if done {
+			break
+		}
+	}
+	telemetryID := recorder.close()
+	if eval.LogLandings {
+		logLanding(episode.Rocket, episode.Outcome, opts.InputType, opts.Level, 0, seed, telemetryID)
+	}
+	return evalResult{
+		Seed:    seed,
+		Score:   sim.FlightScore(episode.Rocket, episode.Outcome),
+		Outcome: episode.Outcome.String(),
+		Ticks:   episode.Rocket.Ticks(),
+	}, nil
+}
+
+func newEvalReport(opts Options, suite string, results []evalResult) evalReport {
+	report := evalReport{
+		Controller:    input.InputString[opts.InputType],
+		Suite:         suite,
+		Level:         opts.Level,
+		Limits:        opts.Limits,
+		Scorer:        sim.DefaultScorer.Name(),
+		ScorerVersion: sim.DefaultScorer.Version(),
+		ScorerWeights: sim.DefaultScorer.Weights(),
+		Flights:       len(results),
+		Outcomes:      map[string]int{},
+		Results:       results,
+	}
+	scores := make([]float64, len(results))
+	sum := 0.0
+	for i, result := range results {
+		if result.Outcome == sim.OutcomeSuccess.String() {
+			report.Successes++
+		}
+		report.Outcomes[result.Outcome]++
+		scores[i] = float64(result.Score)
+		sum += scores[i]
+	}
+	if len(results) == 0 {
+		return report
+	}
+	sort.Float64s(scores)
+	report.SuccessRate = float64(report.Successes) / float64(len(results))
+	report.Score = scoreDistribution{
+		Mean: sum / float64(len(scores)),
+		Min:  scores[0],
+		P10:  percentile(scores, 0.1),
+		P25:  percentile(scores, 0.25),
+		P50:  percentile(scores, 0.5),
+		P75:  percentile(scores, 0.75),
+		P90:  percentile(scores, 0.9),
+		Max:  scores[len(scores)-1],
+	}
+	return report
+}
+
+func (r evalReport) print() {
+	fmt.Printf("%v on suite %v (level %v): %v flights, %0.1f%% successful\n", r.Controller, r.Suite, r.Level, r.Flights, r.SuccessRate*100)
+	fmt.Printf("Score (%v v%v, weights %v): mean %0.2f, min %0.2f, p10 %0.2f, p25 %0.2f, p50 %0.2f, p75 %0.2f, p90 %0.2f, max %0.2f\n",
+		r.Scorer, r.ScorerVersion, r.ScorerWeights, r.Score.Mean, r.Score.Min, r.Score.P10, r.Score.P25, r.Score.P50, r.Score.P75, r.Score.P90, r.Score.Max)
+	for outcome := sim.OutcomeSuccess; outcome <= sim.OutcomeAborted; outcome++ {
+		if count := r.Outcomes[outcome.String()]; count > 0 {
+			fmt.Printf("  %-18v %5v (%0.1f%%)\n", outcome.String()+":", count, float64(count)*100/float64(r.Flights))
+		}
+	}
+}
+
+// compare prints how r fares against baseline, returning true if it regressed by more than the tolerances
+func (r evalReport) compare(baseline evalReport, rateTolerance, scoreTolerance float64) (regressed bool) {
+	fmt.Printf("Against baseline (%v): success rate %+0.1f%%, median score %+0.2f\n",
+		baseline.Controller, (r.SuccessRate-baseline.SuccessRate)*100, r.Score.P50-baseline.Score.P50)
+	if r.SuccessRate < baseline.SuccessRate-rateTolerance {
+		fmt.Println("REGRESSION: success rate dropped more than the tolerance")
+		regressed = true
+	}
+	if r.Score.P50 < baseline.Score.P50-scoreTolerance {
+		fmt.Println("REGRESSION: median score dropped more than the tolerance")
+		regressed = true
+	}
+	return
+}
+
+func readReport(path string, report *evalReport) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, report); err != nil {
+		return fmt.Errorf("report %v could not be parsed: %v", path, err)
+	}
+	return nil
+}
+
+func writeReport(path string, report evalReport) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return helpers.WriteFile(path, append(data, '\n'))
+}
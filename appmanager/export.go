@@ -0,0 +1,238 @@
+package appmanager
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Export formats
+const (
+	// ExportCSV writes one CSV file per table
+	ExportCSV = "csv"
+	// ExportColumnar writes one columnar binary file per table (see columnar.go)
+	ExportColumnar = "columnar"
+)
+
+// Columns are only ever appended to, so scripts reading exports keep working
+var (
+	landingColumns = []exportColumn{
+		{"ID", columnInt},
+		{"Input", columnString},
+		{"Timestamp", columnString},
+		{"Seed", columnInt},
+		{"Fps", columnInt},
+		{"Flighttime", columnFloat64},
+		{"Score", columnFloat32},
+		{"X", columnFloat32},
+		{"Y", columnFloat32},
+		{"VerticalSpeed", columnFloat32},
+		{"HorizontalSpeed", columnFloat32},
+		{"Fuel", columnFloat32},
+		{"Direction", columnFloat32},
+		{"LandingThrust", columnFloat32},
+		{"TelemetryID", columnString},
+		{"Outcome", columnString},
+		{"Level", columnInt},
+		{"Scorer", columnString},
+		{"ScorerVersion", columnInt},
+		{"ScorerWeights", columnString},
+	}
+	telemetryColumns = []exportColumn{
+		{"TelemetryID", columnString},
+		{"Seed", columnInt},
+		{"Input", columnString},
+		{"Tick", columnInt},
+		{"Time", columnFloat32},
+		{"X", columnFloat32},
+		{"Y", columnFloat32},
+		{"HorizontalSpeed", columnFloat32},
+		{"VerticalSpeed", columnFloat32},
+		{"Direction", columnFloat32},
+		{"AngularMomentum", columnFloat32},
+		{"Thrust", columnFloat32},
+		{"Fuel", columnFloat32},
+		{"Ignitions", columnInt},
+		{"Ascending", columnBool},
+		{"ActionEngineOn", columnBool},
+		{"ActionThrust", columnFloat32},
+		{"ActionRCS", columnInt},
+		{"ActionGimbal", columnFloat32},
+	}
+)
+
+func landingRow(log landingLog) []interface{} {
+	return []interface{}{
+		log.ID,
+		log.Input,
+		log.Timestamp,
+		log.Seed,
+		log.Fps,
+		log.Flighttime,
+		log.Score,
+		log.X,
+		log.Y,
+		log.VerticalSpeed,
+		log.HorizontalSpeed,
+		log.Fuel,
+		log.Direction,
+		log.LandingThrust,
+		log.TelemetryID,
+		log.Outcome,
+		log.Level,
+		log.Scorer,
+		log.ScorerVersion,
+		log.ScorerWeights,
+	}
+}
+
+func telemetryRow(header telemetryHeader, tick telemetryTick) []interface{} {
+	return []interface{}{
+		header.ID,
+		header.Seed,
+		header.Input,
+		tick.Tick,
+		tick.Time,
+		tick.X,
+		tick.Y,
+		tick.HorizontalSpeed,
+		tick.VerticalSpeed,
+		tick.Direction,
+		tick.AngularMomentum,
+		tick.Thrust,
+		tick.Fuel,
+		tick.Ignitions,
+		tick.Ascending,
+		tick.Action.EngineOn,
+		tick.Action.Thrust,
+		tick.Action.RCS,
+		tick.Action.Gimbal,
+	}
+}
+
+// csvWriter writes rows as CSV with a header line of column names
+type csvWriter struct {
+	file   *os.File
+	writer *csv.Writer
+	record []string
+}
+
+func newCSVWriter(path string, columns []exportColumn) (*csvWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	cw := &csvWriter{
+		file:   file,
+		writer: csv.NewWriter(file),
+		record: make([]string, len(columns)),
+	}
+	for i, column := range columns {
+		cw.record[i] = column.Name
+	}
+	return cw, cw.writer.Write(cw.record)
+}
+
+func (cw *csvWriter) write(row []interface{}) error {
+	for i, value := range row {
+		switch v := value.(type) {
+		case float32:
+			cw.record[i] = strconv.FormatFloat(float64(v), 'g', -1, 32)
+		case float64:
+			cw.record[i] = strconv.FormatFloat(v, 'g', -1, 64)
+		default:
+			cw.record[i] = fmt.Sprint(v)
+		}
+	}
+	return cw.writer.Write(cw.record)
+}
+
+func (cw *csvWriter) close() error {
+	cw.writer.Flush()
+	if err := cw.writer.Error(); err != nil {
+		cw.file.Close()
+		return err
+	}
+	return cw.file.Close()
+}
+
+func newTableWriter(format, path string, columns []exportColumn) (tableWriter, error) {
+	switch format {
+	case ExportCSV:
+		return newCSVWriter(path+".csv", columns)
+	case ExportColumnar:
+		return newColumnarWriter(path+".rlc", columns)
+	default:
+		return nil, fmt.Errorf("unknown export format %v", format)
+	}
+}
+
+// Export streams the landing log at logPath (and every flight telemetry next to it if
+// withTelemetry) to tables named landings and telemetry in outDir
+func Export(logPath, outDir, format string, withTelemetry bool) error {
+	if _, err := openLogStore(logPath); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+
+	landings, err := newTableWriter(format, filepath.Join(outDir, "landings"), landingColumns)
+	if err != nil {
+		return err
+	}
+	count := 0
+	err = readLandingLogs(logPath, func(log landingLog) error {
+		count++
+		return landings.write(landingRow(log))
+	})
+	if closeErr := landings.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("Exported", count, "landings to", outDir)
+	if !withTelemetry {
+		return nil
+	}
+
+	dir := filepath.Join(filepath.Dir(logPath), telemetryDirName)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	telemetry, err := newTableWriter(format, filepath.Join(outDir, "telemetry"), telemetryColumns)
+	if err != nil {
+		return err
+	}
+	flights := 0
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".jsonl") {
+			continue
+		}
+		// Unreadable telemetry files are skipped, but a failed write leaves the export incomplete
+		var writeErr error
+		_, err = streamTelemetry(filepath.Join(dir, file.Name()), func(header telemetryHeader, tick telemetryTick) error {
+			writeErr = telemetry.write(telemetryRow(header, tick))
+			return writeErr
+		})
+		if writeErr != nil {
+			telemetry.close()
+			return writeErr
+		}
+		if err != nil {
+			fmt.Println("Skipping rest of telemetry file", file.Name()+":", err.Error())
+		}
+		flights++
+	}
+	if err := telemetry.close(); err != nil {
+		return err
+	}
+	fmt.Println("Exported telemetry of", flights, "flights to", outDir)
+	return nil
+}
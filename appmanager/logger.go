@@ -1,9 +1,16 @@
 package appmanager
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/renatobrittoaraujo/rl/helpers"
@@ -11,13 +18,28 @@ import (
 	"github.com/renatobrittoaraujo/rl/sim"
 )
 
+const (
+	// DefaultLogPath is where landings are logged if no other path is given
+	DefaultLogPath = "logs/landing_logs.jsonl"
+	// legacyLogExt is the extension of the old single JSON document log, migrated on first use
+	legacyLogExt = ".json"
+	// logTimeFormat is the format of landingLog.Timestamp
+	logTimeFormat = "2006-01-02T15:04:05.999999-07:00"
+)
+
 type landingLog struct {
 	ID              int
+	Input           string // input.InputString of the landing's input type
 	Timestamp       string
 	Seed            int
+	Level           int
 	Fps             int
 	Flighttime      float64 // seconds
-	Score           float32
+	Score           float32 // sim.FlightScore, landings logged before outcomes have the LandingScore of flights that never landed
+	Scorer          string  // sim.Scorer name of Score, scores only compare between equal scorers, versions and weights
+	ScorerVersion   int
+	ScorerWeights   string
+	Outcome         string // sim.Outcome of the flight
 	X               float32
 	Y               float32
 	VerticalSpeed   float32
@@ -25,8 +47,10 @@ type landingLog struct {
 	Fuel            float32
 	Direction       float32
 	LandingThrust   float32
+	TelemetryID     string // name of the flight's file in telemetryDir, empty if it was not recorded
 }
 
+// landingsLog is the legacy log format, a single JSON document rewritten on every landing
 type landingsLog struct {
 	NewID          int
 	AIInput        []landingLog
@@ -34,35 +58,246 @@ type landingsLog struct {
 	UserInput      []landingLog
 }
 
-func logLanding(rocket *sim.Rocket, inputType int, fps int, seed int) {
-	file, err := ioutil.ReadFile("logs/landing_logs.json")
+// logStore is an append-only JSONL file with one landingLog per line
+//
+// Every landing is written with a single append, so a crash can at most leave a
+// truncated last line (which readers skip). Appends hold a lock file and first
+// read what other processes appended, so IDs stay monotonic across processes
+type logStore struct {
+	path    string
+	mutex   sync.Mutex
+	nextID  int
+	scanned int64 // bytes of the file whose IDs were read into nextID
+}
+
+// staleLockAge is how old a lock file must be to have been left behind by a crashed process, appends take milliseconds
+const staleLockAge = 10 * time.Second
+
+var landingStore *logStore
+
+// openLogs opens the landing log at path for logLanding, with flight telemetry recorded next to it
+func openLogs(path string) {
+	store, err := openLogStore(path)
+	if err != nil {
+		panic("Could not open landing log \"" + path + "\": " + err.Error())
+	}
+	landingStore = store
+	telemetryDir = filepath.Join(filepath.Dir(path), telemetryDirName)
+	demonstrationsDir = DemonstrationsDir(path)
+}
+
+// openLogStore opens the log at path, migrating the legacy log next to it if
+// path does not exist yet
+func openLogStore(path string) (*logStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	ls := &logStore{path: path}
+	// Locked so that a process appending or migrating at the same time never has its landings replaced by a migration
+	unlock, err := ls.lock()
 	if err != nil {
-		fmt.Println("Log was not found, creating file...")
-		jsonFile, _ := json.Marshal(landingsLog{0, []landingLog{}, []landingLog{}, []landingLog{}})
-		err = ioutil.WriteFile("logs/landing_logs.json", []byte(jsonFile), 0644)
-		file, _ = ioutil.ReadFile("logs/landing_logs.json")
-		if err != nil {
-			fmt.Println("Could not create file 'logs/lading_logs.json', exiting logger")
-			fmt.Println(err.Error())
-			return
+		return nil, err
+	}
+	defer unlock()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := ls.migrate(strings.TrimSuffix(path, filepath.Ext(path)) + legacyLogExt); err != nil {
+			return nil, err
+		}
+	}
+	return ls, nil
+}
+
+// migrate converts the legacy log at legacyPath into the store, does nothing if there is no legacy log
+func (ls *logStore) migrate(legacyPath string) error {
+	if legacyPath == ls.path {
+		return nil
+	}
+	file, err := ioutil.ReadFile(legacyPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var legacy landingsLog
+	if err := json.Unmarshal(file, &legacy); err != nil {
+		return fmt.Errorf("legacy log %v could not be parsed: %v", legacyPath, err)
+	}
+	var logs []landingLog
+	for inputType, entries := range map[int][]landingLog{
+		input.AIInput:        legacy.AIInput,
+		input.HardcodedInput: legacy.HardcodedInput,
+		input.UserInput:      legacy.UserInput,
+	} {
+		for _, log := range entries {
+			log.Input = input.InputString[inputType]
+			logs = append(logs, log)
 		}
 	}
+	sort.Slice(logs, func(i, j int) bool { return logs[i].ID < logs[j].ID })
 
-	var logs landingsLog
-	err = json.Unmarshal(file, &logs)
+	// A failed migration never leaves a partial store behind
+	err = helpers.WriteFileWith(ls.path, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		for _, log := range logs {
+			if err := encoder.Encode(log); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("Migrated", len(logs), "landings from", legacyPath, "to", ls.path)
+	return nil
+}
 
+// append assigns log the next ID and appends it to the store
+func (ls *logStore) append(log landingLog) error {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	unlock, err := ls.lock()
 	if err != nil {
-		fmt.Println("Log file could not be parsed correctly, aborting")
-		fmt.Println(err.Error())
+		return err
+	}
+	defer unlock()
+	file, err := os.OpenFile(ls.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	terminated, err := ls.scan(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	log.ID = ls.nextID
+	line, err := json.Marshal(log)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	// A line truncated by a crash is ended first, or this landing would be lost with it
+	if !terminated {
+		line = append([]byte{'\n'}, line...)
+	}
+	line = append(line, '\n')
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	ls.nextID++
+	ls.scanned += int64(len(line))
+	return nil
+}
+
+// lock creates the lock file of the store, waiting while another process holds it, and returns what removes it
+func (ls *logStore) lock() (func(), error) {
+	path := ls.path + ".lock"
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		} else if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			breakLock(path, info)
+			continue
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// breakLock removes the lock file at path if it still is the stale lock described by stale
+//
+// It is first renamed to a name of its own, so of the processes breaking the same stale lock only
+// one removes it, and a lock taken again by another process meanwhile is put back instead
+func breakLock(path string, stale os.FileInfo) {
+	broken := fmt.Sprintf("%v.%v.%v", path, os.Getpid(), time.Now().UnixNano())
+	if os.Rename(path, broken) != nil {
 		return
 	}
+	if info, err := os.Stat(broken); err == nil && !os.SameFile(info, stale) {
+		os.Link(broken, path)
+	} else {
+		fmt.Println("Removed stale lock", path)
+	}
+	os.Remove(broken)
+}
+
+// scan reads the IDs of the lines appended to file since the last scan, by any process, and reports whether
+// file ends with a newline
+func (ls *logStore) scan(file *os.File) (bool, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	// A smaller file was replaced, by a migration, so it is read again from the start
+	if info.Size() < ls.scanned {
+		ls.scanned, ls.nextID = 0, 0
+	}
+	if _, err := file.Seek(ls.scanned, io.SeekStart); err != nil {
+		return false, err
+	}
+	terminated := true
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			ls.scanned += int64(len(line))
+			terminated = line[len(line)-1] == '\n'
+			var log landingLog
+			if json.Unmarshal(line, &log) == nil && log.ID >= ls.nextID {
+				ls.nextID = log.ID + 1
+			}
+		}
+		if err == io.EOF {
+			return terminated, nil
+		} else if err != nil {
+			return false, err
+		}
+	}
+}
 
-	newid := logs.NewID
-	logs.NewID++
+// readLandingLogs streams every landing in the store at path to fn, stopping at the first error fn returns
+//
+// Lines that can not be parsed (such as a truncated last line) are skipped
+func readLandingLogs(path string, fn func(landingLog) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var log landingLog
+		if err := json.Unmarshal(scanner.Bytes(), &log); err != nil {
+			fmt.Println("Skipping unparsable landing log at", path+":"+fmt.Sprint(line))
+			continue
+		}
+		if err := fn(log); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
 
+func logLanding(rocket *sim.Rocket, outcome sim.Outcome, inputType int, level int, fps int, seed int, telemetryID string) {
 	log := landingLog{
-		ID:              newid,
-		Score:           sim.LandingScore(rocket),
+		Input:           input.InputString[inputType],
+		Score:           sim.FlightScore(rocket, outcome),
+		Scorer:          sim.DefaultScorer.Name(),
+		ScorerVersion:   sim.DefaultScorer.Version(),
+		ScorerWeights:   sim.DefaultScorer.Weights(),
+		Outcome:         outcome.String(),
 		X:               rocket.Position.X,
 		Y:               rocket.Position.Y,
 		VerticalSpeed:   rocket.SpeedVector.Y,
@@ -72,25 +307,14 @@ func logLanding(rocket *sim.Rocket, inputType int, fps int, seed int) {
 		LandingThrust:   rocket.ThrustPercentage(),
 		Fps:             fps,
 		Seed:            seed,
-		Timestamp:       time.Now().Format("2006-01-02T15:04:05.999999-07:00"),
+		Level:           level,
+		Timestamp:       time.Now().Format(logTimeFormat),
 		Flighttime:      helpers.SubtractTimeInSeconds(rocket.LiftoffTime, time.Now()),
+		TelemetryID:     telemetryID,
 	}
 
-	switch inputType {
-	case input.AIInput:
-		logs.AIInput = append(logs.AIInput, log)
-	case input.HardcodedInput:
-		logs.HardcodedInput = append(logs.HardcodedInput, log)
-	case input.UserInput:
-		logs.UserInput = append(logs.UserInput, log)
-	}
-
-	newJSON, err := json.Marshal(logs)
-	if err != nil {
-		fmt.Println("New log could not be parsed correctly, aborting log")
+	if err := landingStore.append(log); err != nil {
+		fmt.Println("Landing could not be logged to", landingStore.path)
 		fmt.Println(err.Error())
-		return
 	}
-
-	err = ioutil.WriteFile("logs/landing_logs.json", []byte(newJSON), 0644)
 }
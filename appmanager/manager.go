@@ -3,6 +3,7 @@ package appmanager
 import (
 	"fmt"
 	"math/rand"
+	"os"
 	"time"
 
 	"github.com/hajimehoshi/ebiten"
@@ -20,68 +21,99 @@ const (
 
 var (
 	rocketChannel chan *sim.Rocket
-	inputType     int
-	draw          bool
-	loaded        bool
+	options       Options
 	inputManager  input.Manager
 	seed          int
 	createSeed    bool
-	fps           int
+	keysPressed   = map[ebiten.Key]bool{}
+	ghost         *replayer
+	ghostChannel  chan *sim.Rocket
 )
 
-// StartSimulationDriver receives draw bool and run sim with screen drawing or on CLI
-func StartSimulationDriver(argdraw bool, arginputType int, argseed int, argfps int) {
-	inputType = arginputType
-	draw = argdraw
-	if argseed != 0 {
-		seed = argseed
+// Options configure how flights are simulated
+type Options struct {
+	Draw      bool       // draws flights on screen, otherwise they run on CLI
+	InputType int        // input package constant of who controls the rocket
+	Seed      int        // seed of every flight, 0 picks a random seed per flight
+	Fps       int        // physics ticks per second, 0 picks a default for drawn or CLI simulation
+	Level     int        // sim package level
+	Episodes  int        // amount of flights before exiting, 0 flies forever
+	GhostPath string     // flight recording flown alongside as a ghost when drawing
+	LogPath   string     // landing log, flight telemetry is recorded next to it
+	Limits    sim.Limits // limits that end flights that never touch the ground
+}
+
+// StartSimulationDriver runs flights as configured by opts, with screen drawing or on CLI
+func StartSimulationDriver(opts Options) {
+	options = opts
+	openLogs(options.LogPath)
+	if options.GhostPath != "" && options.Draw {
+		lghost, err := newReplayer(options.GhostPath)
+		if err != nil {
+			panic("Could not load ghost recording \"" + options.GhostPath + "\": " + err.Error())
+		}
+		ghost = lghost
+		if options.Seed == 0 {
+			options.Seed = ghost.header.Seed
+		} else if options.Seed != ghost.header.Seed {
+			fmt.Println("Ghost was recorded with seed", ghost.header.Seed, "and will not fly the same ascension")
+		}
+	}
+	if options.Seed != 0 {
+		seed = options.Seed
 	} else {
 		createSeed = true
 	}
-	if argfps != 0 {
-		fps = argfps
-	}
-	linputManager, err := input.CreateInput(inputType)
-	if err {
-		panic("Input \"" + input.InputString[inputType] + "\" has not been initalized correctly")
-	}
-	inputManager = linputManager
+	inputManager = createInputManager()
 	rocketChannel = make(chan *sim.Rocket, 1)
+	if ghost != nil {
+		ghostChannel = make(chan *sim.Rocket, 1)
+		renderer.DrawGhost(ghostChannel)
+	}
 	go startSimulationInstance()
 	renderer.DrawSim(rocketChannel, simDrawFrames)
 }
 
 func startSimulationInstance() {
-	for {
-		rocket := sim.CreateRocket()
+	for flights := 0; options.Episodes == 0 || flights < options.Episodes; flights++ {
 		if createSeed {
 			rand.Seed(time.Now().Local().UnixNano())
 			seed = rand.Int()*100000000 - 50000000
 		}
 		fmt.Println("SEED USED:", seed)
+		episode := sim.NewEpisode(seed, options.Level)
+		episode.Limits = options.Limits
+		rocket := episode.Rocket
 		var cfps int
-		if fps != 0 {
-			cfps = fps
-		} else if draw {
+		if options.Fps != 0 {
+			cfps = options.Fps
+		} else if options.Draw {
 			cfps = simDrawFrames * 20
 		} else {
 			cfps = simCliFrames
 		}
-		if draw {
+		if options.Draw {
 			waitKeyPress(ebiten.KeySpace, nil)
 		}
+		// Controllers keep state of the flight they fly, such as plans, so every flight gets a fresh one
+		if flights > 0 {
+			inputManager = createInputManager()
+		}
+		recorder := newFlightRecorder(seed, cfps, options.InputType, options.Level, options.Limits)
+		var controller sim.Controller = inputManager
+		demonstration := newDemonstrationRecorder(inputManager, options.InputType, seed, options.Level)
+		if demonstration != nil {
+			controller = demonstration
+		}
 		for range time.Tick(time.Second / time.Duration(cfps)) {
-			if draw && ebiten.IsKeyPressed(ebiten.KeyR) {
+			if options.Draw && ebiten.IsKeyPressed(ebiten.KeyR) {
 				break
 			}
-			if rocket.IsAscending() {
-				rocket.Ascend(float32(seed))
-			} else {
-				inputManager.UpdateSim(rocket)
-			}
-			rocket.Update()
-			if col := sim.DetectGroundCollision(rocket); col > 0 && !rocket.IsAscending() {
-				if draw {
+			action, done := episode.Step(controller)
+			recorder.record(rocket, action)
+			demonstration.record(action)
+			if done {
+				if options.Draw {
 					waitKeyPress(ebiten.KeySpace, rocket)
 					time.Sleep(time.Millisecond * 70 /* When spacebar is pressed at the end of simulation, little lag so no overlap with next spacebar press */)
 				}
@@ -90,8 +122,41 @@ func startSimulationInstance() {
 			if len(rocketChannel) < cap(rocketChannel) {
 				rocketChannel <- rocket
 			}
+			sendGhost(rocket.Ticks())
 		}
-		go logLanding(rocket, inputType, cfps, seed)
+		fmt.Println("FLIGHT ENDED:", episode.Outcome)
+		telemetryID := recorder.close()
+		logLanding(rocket, episode.Outcome, options.InputType, options.Level, cfps, seed, telemetryID)
+		demonstration.save(rocket, episode.Outcome, telemetryID)
+	}
+	os.Exit(0)
+}
+
+func createInputManager() input.Manager {
+	linputManager, inputErr := input.CreateInput(options.InputType)
+	if inputErr {
+		panic("Input \"" + input.InputString[options.InputType] + "\" has not been initalized correctly")
+	}
+	return linputManager
+}
+
+// sendGhost feeds the renderer the ghost as it was after the same amount of ticks as the live rocket
+//
+// A ghost that diverges from its recording is no longer drawn, the live flight goes on
+func sendGhost(ticks int) {
+	if ghost == nil {
+		return
+	}
+	tick, err := ghost.seek(ticks)
+	if err != nil {
+		fmt.Println("Warning: ghost is no longer drawn,", err)
+		ghost = nil
+		ghostChannel <- nil
+		return
+	}
+	ghostRocket := ghost.history[tick]
+	if len(ghostChannel) < cap(ghostChannel) {
+		ghostChannel <- &ghostRocket
 	}
 }
 
@@ -113,3 +178,11 @@ func waitKeyPress(key ebiten.Key, rocket *sim.Rocket) {
 		}
 	}
 }
+
+// keyJustPressed returns true only on the first call after key started being pressed
+func keyJustPressed(key ebiten.Key) bool {
+	pressed := ebiten.IsKeyPressed(key)
+	just := pressed && !keysPressed[key]
+	keysPressed[key] = pressed
+	return just
+}
@@ -0,0 +1,168 @@
+package appmanager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/renatobrittoaraujo/rl/renderer"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+const (
+	maxReplaySpeed = 64
+	minReplaySpeed = 1.0 / 16
+)
+
+// replayer re-simulates a recorded flight from its seed and actions,
+// checking every tick against the recorded state
+type replayer struct {
+	header  telemetryHeader
+	ticks   []telemetryTick
+	episode *sim.Episode
+	history []sim.Rocket // history[i] is the rocket after i ticks
+}
+
+// StartReplayDriver re-simulates the flight recorded at path
+//
+// When drawing, the flight is played on screen with playback controls, otherwise
+// it is re-simulated as fast as possible and checked against its recording
+func StartReplayDriver(argdraw bool, path string) {
+	rp, err := newReplayer(path)
+	if err != nil {
+		panic("Could not load flight recording \"" + path + "\": " + err.Error())
+	}
+	fmt.Println("REPLAYING FLIGHT:", rp.header.ID, "SEED:", rp.header.Seed, "INPUT:", rp.header.Input)
+	if !argdraw {
+		for {
+			more, err := rp.step()
+			if err != nil {
+				panic(err.Error())
+			}
+			if !more {
+				break
+			}
+		}
+		fmt.Println("Replay matches recording,", len(rp.ticks), "ticks re-simulated, landing score", sim.LandingScore(rp.episode.Rocket))
+		return
+	}
+	fmt.Println("SPACE pause/resume, RIGHT step forward, LEFT step back (hold while playing to rewind), UP/DOWN playback speed, R restart")
+	rocketChannel = make(chan *sim.Rocket, 1)
+	go startReplayInstance(rp)
+	renderer.DrawSim(rocketChannel, simDrawFrames)
+}
+
+func newReplayer(path string) (*replayer, error) {
+	header, ticks, err := readTelemetry(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(ticks) == 0 {
+		return nil, fmt.Errorf("recording has no ticks")
+	}
+	episode := sim.NewEpisodeWith(header.Seed, header.Level, header.Vehicle, header.Physics)
+	episode.Limits = header.Limits
+	return &replayer{
+		header:  header,
+		ticks:   ticks,
+		episode: episode,
+		history: []sim.Rocket{*episode.Rocket},
+	}, nil
+}
+
+// Act feeds the recorded actions back into the episode
+func (rp *replayer) Act(obs sim.Observation) sim.Action {
+	return rp.ticks[rp.episode.Rocket.Ticks()].Action
+}
+
+// step re-simulates the next recorded tick, returns false if there are none left
+//
+// Returns an error if the re-simulated flight diverges from its recording
+func (rp *replayer) step() (bool, error) {
+	i := rp.episode.Rocket.Ticks()
+	if i >= len(rp.ticks) {
+		return false, nil
+	}
+	action, done := rp.episode.Step(rp)
+	if got := newTelemetryTick(rp.episode.Rocket, action); got != rp.ticks[i] {
+		return false, fmt.Errorf("Replay of flight %v diverged from its recording at tick %v\n recorded:     %+v\n re-simulated: %+v", rp.header.ID, i+1, rp.ticks[i], got)
+	}
+	if done && i+1 < len(rp.ticks) {
+		return false, fmt.Errorf("Replay of flight %v ended (%v) at tick %v, but recording has %v ticks", rp.header.ID, rp.episode.Outcome, i+1, len(rp.ticks))
+	}
+	rp.history = append(rp.history, *rp.episode.Rocket)
+	return true, nil
+}
+
+// seek returns the closest tick to target that has been re-simulated, simulating up to it if needed
+//
+// On divergence it returns the last tick that matched the recording and the error
+func (rp *replayer) seek(target int) (int, error) {
+	if target < 0 {
+		target = 0
+	}
+	for len(rp.history)-1 < target {
+		more, err := rp.step()
+		if err != nil {
+			return len(rp.history) - 1, err
+		}
+		if !more {
+			break
+		}
+	}
+	if target > len(rp.history)-1 {
+		target = len(rp.history) - 1
+	}
+	return target, nil
+}
+
+func startReplayInstance(rp *replayer) {
+	fps := rp.header.Fps
+	if fps <= 0 {
+		fps = simDrawFrames * 20
+	}
+	cursor := 0
+	speed := 1.0
+	progress := 0.0
+	paused := true
+	for range time.Tick(time.Second / simDrawFrames) {
+		if keyJustPressed(ebiten.KeySpace) {
+			paused = !paused
+		}
+		if keyJustPressed(ebiten.KeyUp) && speed < maxReplaySpeed {
+			speed *= 2
+		}
+		if keyJustPressed(ebiten.KeyDown) && speed > minReplaySpeed {
+			speed /= 2
+		}
+		if keyJustPressed(ebiten.KeyR) {
+			cursor = 0
+			paused = true
+		}
+		stepForward := keyJustPressed(ebiten.KeyRight)
+		stepBack := keyJustPressed(ebiten.KeyLeft)
+		steps := 0
+		if paused {
+			if stepForward {
+				steps = 1
+			} else if stepBack {
+				steps = -1
+			}
+		} else {
+			progress += float64(fps) * speed / simDrawFrames
+			steps = int(progress)
+			progress -= float64(steps)
+			if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+				steps = -steps
+			}
+		}
+		var err error
+		if cursor, err = rp.seek(cursor + steps); err != nil {
+			panic(err.Error())
+		}
+		rocket := rp.history[cursor]
+		if len(rocketChannel) < cap(rocketChannel) {
+			rocketChannel <- &rocket
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package appmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/renatobrittoaraujo/rl/input"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// StatsFilter selects which landings are summarized, zero values match every landing
+type StatsFilter struct {
+	From time.Time
+	To   time.Time
+	Seed int
+	Fps  int
+}
+
+// inputStats summarizes all landings of a single input type rated by a single scorer
+type inputStats struct {
+	Input          string
+	Scorer         string // scorerOf the landings, empty if there are none
+	Attempts       int
+	Successes      int
+	SuccessRate    float64
+	ScoreP10       float64
+	ScoreP50       float64
+	ScoreP90       float64
+	MeanSpeed      float64 // m/s at touchdown
+	MeanAngleError float64 // degrees from upright at touchdown
+	MeanFuelLeft   float64 // percentage [0.0, 1.0]
+	scores         []float64
+	speedSum       float64
+	angleErrorSum  float64
+	fuelSum        float64
+}
+
+func (f StatsFilter) match(log landingLog) bool {
+	if f.Seed != 0 && log.Seed != f.Seed {
+		return false
+	}
+	if f.Fps != 0 && log.Fps != f.Fps {
+		return false
+	}
+	if f.From.IsZero() && f.To.IsZero() {
+		return true
+	}
+	timestamp, err := time.Parse(logTimeFormat, log.Timestamp)
+	if err != nil {
+		return false
+	}
+	if !f.From.IsZero() && timestamp.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !timestamp.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// scorerOf names the scorer, version and weights of a landing's score, landings logged before scorers were all
+// rated by logistic version 1
+func scorerOf(log landingLog) string {
+	if log.Scorer == "" {
+		return sim.ScorerLogistic + " v1"
+	}
+	return strings.TrimSpace(fmt.Sprintf("%v v%v %v", log.Scorer, log.ScorerVersion, log.ScorerWeights))
+}
+
+// successful tells whether a landing succeeded by its outcome, landings logged before outcomes by a non negative score
+func successful(log landingLog) bool {
+	if log.Outcome == "" {
+		return log.Score >= 0
+	}
+	return log.Outcome == sim.OutcomeSuccess.String()
+}
+
+func (s *inputStats) add(log landingLog) {
+	s.Attempts++
+	if successful(log) {
+		s.Successes++
+	}
+	s.scores = append(s.scores, float64(log.Score))
+	s.speedSum += math.Hypot(float64(log.HorizontalSpeed), float64(log.VerticalSpeed))
+	s.angleErrorSum += math.Abs(math.Pi/2-float64(log.Direction)) * 180 / math.Pi
+	s.fuelSum += float64(log.Fuel)
+}
+
+func (s *inputStats) finish() {
+	if s.Attempts == 0 {
+		return
+	}
+	attempts := float64(s.Attempts)
+	s.SuccessRate = float64(s.Successes) / attempts
+	sort.Float64s(s.scores)
+	s.ScoreP10 = percentile(s.scores, 0.1)
+	s.ScoreP50 = percentile(s.scores, 0.5)
+	s.ScoreP90 = percentile(s.scores, 0.9)
+	s.MeanSpeed = s.speedSum / attempts
+	s.MeanAngleError = s.angleErrorSum / attempts
+	s.MeanFuelLeft = s.fuelSum / attempts
+}
+
+// percentile linearly interpolates the p-th percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	pos := p * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
+
+// PrintStats summarizes landings in the log at path per input type and scorer, as a table or as JSON
+//
+// Scores only compare between equal scorers, so each input gets a row for every scorer of its landings
+func PrintStats(path string, filter StatsFilter, asJSON bool) error {
+	inputs := []int{input.AIInput, input.HardcodedInput, input.UserInput, input.QLearningInput, input.GuidanceInput, input.MPCInput, input.AutopilotInput}
+	byScorer := map[string]map[string]*inputStats{}
+	for _, inputType := range inputs {
+		byScorer[input.InputString[inputType]] = map[string]*inputStats{}
+	}
+	// Opening the store migrates a legacy log that has not been migrated yet
+	if _, err := openLogStore(path); err != nil {
+		return err
+	}
+	err := readLandingLogs(path, func(log landingLog) error {
+		scorers, ok := byScorer[log.Input]
+		if !ok || !filter.match(log) {
+			return nil
+		}
+		scorer := scorerOf(log)
+		if scorers[scorer] == nil {
+			scorers[scorer] = &inputStats{Input: log.Input + "Input", Scorer: scorer}
+		}
+		scorers[scorer].add(log)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var ordered []*inputStats
+	for _, inputType := range inputs {
+		scorers := byScorer[input.InputString[inputType]]
+		if len(scorers) == 0 {
+			ordered = append(ordered, &inputStats{Input: input.InputString[inputType] + "Input"})
+			continue
+		}
+		names := make([]string, 0, len(scorers))
+		for name := range scorers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			scorers[name].finish()
+			ordered = append(ordered, scorers[name])
+		}
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(ordered)
+	}
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "Input\tScorer\tAttempts\tSuccess\tScore p10\tp50\tp90\tSpeed (m/s)\tAngle Error (°)\tFuel Left\t")
+	for _, s := range ordered {
+		if s.Attempts == 0 {
+			fmt.Fprintf(table, "%v\t-\t0\t-\t-\t-\t-\t-\t-\t-\t\n", s.Input)
+			continue
+		}
+		fmt.Fprintf(table, "%v\t%v\t%v\t%0.1f%%\t%0.2f\t%0.2f\t%0.2f\t%0.2f\t%0.2f\t%0.1f%%\t\n",
+			s.Input, s.Scorer, s.Attempts, s.SuccessRate*100, s.ScoreP10, s.ScoreP50, s.ScoreP90,
+			s.MeanSpeed, s.MeanAngleError, s.MeanFuelLeft*100)
+	}
+	return table.Flush()
+}
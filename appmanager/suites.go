@@ -0,0 +1,86 @@
+package appmanager
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// Seed suites are fixed lists of seeds, so results of different controllers are comparable
+const (
+	// SuiteSmoke is a quick sanity check of 50 seeds
+	SuiteSmoke = "smoke"
+	// SuiteStandard is 1000 consecutive seeds
+	SuiteStandard = "standard"
+	// SuiteCurated holds hand picked scenarios: extreme leans and ascension lengths
+	SuiteCurated = "curated"
+	// SuiteFull is SuiteStandard followed by SuiteCurated
+	SuiteFull = "full"
+)
+
+// SuiteNames lists every seed suite
+var SuiteNames = []string{SuiteSmoke, SuiteStandard, SuiteCurated, SuiteFull}
+
+// curatedCandidates is how many seeds are searched for curated scenarios
+const curatedCandidates = 100000
+
+// seedSuite returns the seeds of the suite called name
+func seedSuite(name string) ([]int, error) {
+	switch name {
+	case SuiteSmoke:
+		return seedRange(1, 50), nil
+	case SuiteStandard:
+		return seedRange(1, 1000), nil
+	case SuiteCurated:
+		return curatedSeeds(), nil
+	case SuiteFull:
+		return append(seedRange(1, 1000), curatedSeeds()...), nil
+	}
+	return nil, fmt.Errorf("unknown seed suite %q", name)
+}
+
+func seedRange(first, last int) []int {
+	seeds := make([]int, 0, last-first+1)
+	for seed := first; seed <= last; seed++ {
+		seeds = append(seeds, seed)
+	}
+	return seeds
+}
+
+// curatedSeeds picks seeds whose ascensions are the hardest to recover from: seed 1 (straight up)
+// and the seeds with the strongest lean to each side combined with short, average and long ascensions
+func curatedSeeds() []int {
+	type candidate struct {
+		seed      int
+		lean      float64
+		ascension float64
+	}
+	candidates := make([]candidate, 0, curatedCandidates)
+	for seed := 2; seed <= curatedCandidates; seed++ {
+		lean, ascension := sim.AscentProfile(float32(seed))
+		candidates = append(candidates, candidate{
+			seed:      seed,
+			lean:      float64(lean),
+			ascension: float64(ascension),
+		})
+	}
+	seeds := []int{1}
+	for _, lean := range []float64{-1, 1} {
+		for _, ascension := range []float64{-1, 0, 1} {
+			sort.Slice(candidates, func(i, j int) bool {
+				return distance(candidates[i].lean, candidates[i].ascension, lean, ascension) <
+					distance(candidates[j].lean, candidates[j].ascension, lean, ascension)
+			})
+			for _, c := range candidates[:5] {
+				seeds = append(seeds, c.seed)
+			}
+		}
+	}
+	return seeds
+}
+
+func distance(x1, y1, x2, y2 float64) float64 {
+	return math.Hypot(x1-x2, y1-y2)
+}
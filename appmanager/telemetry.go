@@ -0,0 +1,183 @@
+package appmanager
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/renatobrittoaraujo/rl/input"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+const (
+	// telemetryDirName is the directory next to the landing log that holds one
+	// telemetry file per flight, named after its ID
+	telemetryDirName = "telemetry"
+	// telemetryVersion is bumped whenever telemetryHeader or telemetryTick change
+	telemetryVersion = 2
+)
+
+// telemetryHeader is the first line of every telemetry file
+type telemetryHeader struct {
+	Version int
+	ID      string
+	Seed    int
+	Fps     int
+	Input   string
+	Level   int
+	Limits  sim.Limits
+	Vehicle sim.Vehicle
+	Physics sim.Physics
+}
+
+// telemetryTick is every other line of a telemetry file, one per physics tick
+type telemetryTick struct {
+	Tick            int
+	Time            float32 // simulated seconds
+	X               float32
+	Y               float32
+	HorizontalSpeed float32
+	VerticalSpeed   float32
+	Direction       float32
+	AngularMomentum float32
+	Thrust          float32
+	Fuel            float32
+	Ignitions       int
+	Ascending       bool
+	Action          sim.Action
+}
+
+// newTelemetryTick captures rocket state after a physics tick
+func newTelemetryTick(rocket *sim.Rocket, action sim.Action) telemetryTick {
+	return telemetryTick{
+		Tick:            rocket.Ticks(),
+		Time:            rocket.SimulatedTime(),
+		X:               rocket.Position.X,
+		Y:               rocket.Position.Y,
+		HorizontalSpeed: rocket.SpeedVector.X,
+		VerticalSpeed:   rocket.SpeedVector.Y,
+		Direction:       rocket.Direction,
+		AngularMomentum: rocket.AngularMomentum,
+		Thrust:          rocket.ThrustPercentage(),
+		Fuel:            rocket.FuelPercentage(),
+		Ignitions:       rocket.EngineStartsRemaining,
+		Ascending:       rocket.IsAscending(),
+		Action:          action,
+	}
+}
+
+// flightRecorder writes every physics tick of a flight to a JSONL file
+type flightRecorder struct {
+	ID      string
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+var (
+	telemetryDir     = filepath.Join("logs", telemetryDirName)
+	telemetryCounter int64
+)
+
+// newTelemetryID returns an unique ID for a flight, sortable by creation time
+func newTelemetryID() string {
+	return fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405.000000"), atomic.AddInt64(&telemetryCounter, 1))
+}
+
+// newFlightRecorder creates the telemetry file of a new flight and writes its header
+//
+// Returns nil if the file could not be created, a nil recorder ignores all calls
+func newFlightRecorder(seed, fps, inputType, level int, limits sim.Limits) *flightRecorder {
+	id := newTelemetryID()
+	if err := os.MkdirAll(telemetryDir, 0755); err != nil {
+		fmt.Println("Could not create telemetry directory, flight will not be recorded")
+		fmt.Println(err.Error())
+		return nil
+	}
+	file, err := os.Create(filepath.Join(telemetryDir, id+".jsonl"))
+	if err != nil {
+		fmt.Println("Could not create telemetry file, flight will not be recorded")
+		fmt.Println(err.Error())
+		return nil
+	}
+	writer := bufio.NewWriter(file)
+	fr := &flightRecorder{
+		ID:      id,
+		file:    file,
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+	}
+	fr.encoder.Encode(telemetryHeader{
+		Version: telemetryVersion,
+		ID:      id,
+		Seed:    seed,
+		Fps:     fps,
+		Input:   input.InputString[inputType],
+		Level:   level,
+		Limits:  limits,
+		Vehicle: sim.DefaultVehicle,
+		Physics: sim.DefaultPhysics,
+	})
+	return fr
+}
+
+// record writes rocket state after a physics tick along with the action applied on it
+func (fr *flightRecorder) record(rocket *sim.Rocket, action sim.Action) {
+	if fr == nil {
+		return
+	}
+	fr.encoder.Encode(newTelemetryTick(rocket, action))
+}
+
+// readTelemetry loads a telemetry file written by flightRecorder
+func readTelemetry(path string) (header telemetryHeader, ticks []telemetryTick, err error) {
+	header, err = streamTelemetry(path, func(_ telemetryHeader, tick telemetryTick) error {
+		ticks = append(ticks, tick)
+		return nil
+	})
+	return
+}
+
+// streamTelemetry reads a telemetry file written by flightRecorder one tick at a time, stopping at the first error fn returns
+func streamTelemetry(path string, fn func(telemetryHeader, telemetryTick) error) (header telemetryHeader, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	if err = decoder.Decode(&header); err != nil {
+		return
+	}
+	if header.Version != telemetryVersion {
+		err = fmt.Errorf("telemetry file has version %v, expected %v", header.Version, telemetryVersion)
+		return
+	}
+	for decoder.More() {
+		var tick telemetryTick
+		if err = decoder.Decode(&tick); err != nil {
+			return
+		}
+		if err = fn(header, tick); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// close flushes and closes the telemetry file, returning the flight ID ("" if nothing was recorded)
+func (fr *flightRecorder) close() string {
+	if fr == nil {
+		return ""
+	}
+	if err := fr.writer.Flush(); err != nil {
+		fmt.Println("Could not write telemetry of flight", fr.ID)
+		fmt.Println(err.Error())
+	}
+	fr.file.Close()
+	return fr.ID
+}
@@ -0,0 +1,513 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/renatobrittoaraujo/rl/appmanager"
+	"github.com/renatobrittoaraujo/rl/config"
+	"github.com/renatobrittoaraujo/rl/input"
+	"github.com/renatobrittoaraujo/rl/runs"
+	"github.com/renatobrittoaraujo/rl/train"
+)
+
+// command is a CLI subcommand, run receives every argument after the command's name
+type command struct {
+	name    string
+	summary string
+	run     func(cmd *command, args []string) error
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"fly", "fly the rocket, drawn on screen unless --headless", runFly},
+		{"train", "train a controller with --trainer, or fly the rocket on CLI as fast as --fps allows", runTrain},
+		{"eval", "benchmark a controller over a fixed seed suite, optionally against a baseline report", runEval},
+		{"replay", "re-simulate a recorded flight, checking it against its recording", runReplay},
+		{"stats", "summarize the landing log per input type", runStats},
+		{"export", "export the landing log (and telemetry) to CSV or columnar files", runExport},
+		{"runs", "list train and eval runs, or compare the runs given as arguments", runRuns},
+	}
+}
+
+func findCommand(name string) (*command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return nil, false
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: rl <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8v %v\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun \"rl <command> --help\" for the flags of a command")
+}
+
+// Legacy arguments were positional: an input name, "train"/"draw" and key=value pairs
+var legacyRenames = map[string]string{
+	"logpath":     "log",
+	"maxtime":     "max-time",
+	"maxaltitude": "max-altitude",
+	"maxdrift":    "max-drift",
+	"replay":      "file",
+}
+
+// legacyArgs returns the command to run and its arguments, translating legacy
+// invocations such as "rl user seed=5" into "rl fly --controller=user --seed=5"
+func legacyArgs(args []string) (string, []string) {
+	name := "fly"
+	if len(args) > 0 {
+		if _, ok := findCommand(args[0]); ok {
+			name = args[0]
+			args = args[1:]
+		}
+	}
+	translated := make([]string, 0, len(args))
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			translated = append(translated, arg)
+			continue
+		}
+		if _, ok := input.InputFromName(arg); ok {
+			translated = append(translated, "--controller="+arg)
+			continue
+		}
+		switch arg {
+		case "train":
+			translated = append(translated, "--headless")
+			continue
+		case "draw":
+			translated = append(translated, "--headless=false")
+			continue
+		case "telemetry":
+			translated = append(translated, "--telemetry")
+			continue
+		}
+		split := strings.SplitN(arg, "=", 2)
+		if len(split) != 2 {
+			translated = append(translated, arg)
+			continue
+		}
+		if split[0] == "replay" && name == "fly" {
+			name = "replay"
+		}
+		if rename, ok := legacyRenames[split[0]]; ok {
+			split[0] = rename
+		}
+		translated = append(translated, "--"+split[0]+"="+split[1])
+	}
+	return name, translated
+}
+
+// newFlagSet creates the flag set of cmd, whose --help prints usage
+func newFlagSet(cmd *command, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: rl %v %v\n\n%v\n\nFlags:\n", cmd.name, usage, cmd.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args into fs, returning flag.ErrHelp as is and refusing positional arguments
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return nil
+}
+
+// flightFlags are the flags shared by every command that flies the rocket
+//
+// Flags that are also config fields only override the config when given
+type flightFlags struct {
+	fs                             *flag.FlagSet
+	config                         string
+	seed, fps, level, episodes     int
+	controller, model, out, log    string
+	maxTime, maxAltitude, maxDrift float64
+	escape                         bool
+	effective                      config.Config // set by options
+}
+
+func addFlightFlags(fs *flag.FlagSet, defaultController string) *flightFlags {
+	defaults := config.Default()
+	f := &flightFlags{fs: fs}
+	fs.StringVar(&f.config, "config", "", "JSON config file, its values override defaults and are overridden by flags")
+	fs.IntVar(&f.seed, "seed", 0, "seed of every flight's ascension, 0 picks a random one per flight")
+	fs.IntVar(&f.fps, "fps", 0, "physics ticks per second, 0 picks a default")
+	fs.IntVar(&f.level, "level", defaults.Level, "simulation level: 0 free (3 ignitions), 1 unlimited ignitions, 2 two ignitions, 3 two ignitions and landing pad")
+	fs.IntVar(&f.episodes, "episodes", 0, "amount of flights before exiting, 0 flies forever")
+	fs.StringVar(&f.controller, "controller", defaultController, "who controls the rocket: user, ai, hardcoded, qlearning, guidance, mpc or autopilot")
+	fs.StringVar(&f.model, "model", "", "model file flown by the ai controller (JSON or binary), or Q-table flown by qlearning")
+	fs.StringVar(&f.out, "out", defaults.Logs.Dir, "output directory of landing logs, telemetry and effective configs")
+	fs.StringVar(&f.log, "log", "", "landing log path (default <out>/"+filepath.Base(defaults.LandingLogPath())+")")
+	fs.Float64Var(&f.maxTime, "max-time", float64(defaults.Limits.MaxFlightTime), "simulated seconds before a flight times out, 0 disables")
+	fs.Float64Var(&f.maxAltitude, "max-altitude", float64(defaults.Limits.MaxAltitude), "meters of altitude that end a flight, 0 disables")
+	fs.Float64Var(&f.maxDrift, "max-drift", float64(defaults.Limits.MaxDrift), "meters of horizontal drift that end a flight, 0 disables")
+	fs.BoolVar(&f.escape, "escape", defaults.Limits.Escape, "end flights escaping past --max-altitude under power")
+	return f
+}
+
+// outDir returns the output directory set by --out, the config file or the defaults, without applying them
+func (f *flightFlags) outDir() (string, error) {
+	cfg := config.Default()
+	if f.config != "" {
+		if err := cfg.Load(f.config); err != nil {
+			return "", err
+		}
+	}
+	f.fs.Visit(func(fl *flag.Flag) {
+		if fl.Name == "out" {
+			cfg.Logs.Dir = f.out
+		}
+	})
+	return cfg.Logs.Dir, nil
+}
+
+// options merges defaults, the config file and flags (in this order), applies the resulting
+// config and writes it to the output directory, returning simulation options
+func (f *flightFlags) options() (appmanager.Options, error) {
+	cfg := config.Default()
+	if f.config != "" {
+		if err := cfg.Load(f.config); err != nil {
+			return appmanager.Options{}, err
+		}
+	}
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "level":
+			cfg.Level = f.level
+		case "model":
+			cfg.Controllers.AI.ModelPath = f.model
+			cfg.Controllers.QLearning.TablePath = f.model
+		case "out":
+			cfg.Logs.Dir = f.out
+		case "log":
+			cfg.Logs.LandingLog = f.log
+		case "max-time":
+			cfg.Limits.MaxFlightTime = float32(f.maxTime)
+		case "max-altitude":
+			cfg.Limits.MaxAltitude = float32(f.maxAltitude)
+		case "max-drift":
+			cfg.Limits.MaxDrift = float32(f.maxDrift)
+		case "escape":
+			cfg.Limits.Escape = f.escape
+		}
+	})
+	if err := cfg.Validate(); err != nil {
+		return appmanager.Options{}, err
+	}
+	inputType, ok := input.InputFromName(f.controller)
+	if !ok {
+		return appmanager.Options{}, fmt.Errorf("invalid --controller %q, must be user, ai, hardcoded, qlearning, guidance, mpc or autopilot", f.controller)
+	}
+	if f.fps < 0 {
+		return appmanager.Options{}, fmt.Errorf("invalid --fps %v, must not be negative", f.fps)
+	}
+	if f.episodes < 0 {
+		return appmanager.Options{}, fmt.Errorf("invalid --episodes %v, must not be negative", f.episodes)
+	}
+
+	cfg.Apply()
+	f.effective = cfg
+	configPath := filepath.Join(cfg.Logs.Dir, "configs", time.Now().Format("20060102-150405.000")+".json")
+	if err := cfg.Write(configPath); err != nil {
+		return appmanager.Options{}, fmt.Errorf("effective config could not be written: %v", err)
+	}
+	fmt.Println("EFFECTIVE CONFIG:", configPath)
+	return appmanager.Options{
+		InputType: inputType,
+		Seed:      f.seed,
+		Fps:       f.fps,
+		Level:     cfg.Level,
+		Episodes:  f.episodes,
+		LogPath:   cfg.LandingLogPath(),
+		Limits:    cfg.Limits,
+	}, nil
+}
+
+func runFly(cmd *command, args []string) error {
+	fs := newFlagSet(cmd, "[flags]")
+	flags := addFlightFlags(fs, "ai")
+	headless := fs.Bool("headless", false, "fly on CLI without drawing")
+	ghost := fs.String("ghost", "", "flight recording drawn as a ghost next to the rocket, its seed is used unless --seed is given")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	opts, err := flags.options()
+	if err != nil {
+		return err
+	}
+	opts.Draw = !*headless
+	opts.GhostPath = *ghost
+	appmanager.StartSimulationDriver(opts)
+	return nil
+}
+
+func runTrain(cmd *command, args []string) error {
+	fs := newFlagSet(cmd, "[flags]")
+	flags := addFlightFlags(fs, "ai")
+	trainer := fs.String("trainer", "", "trainer to run: "+strings.Join(train.Trainers, ", ")+", none flies --controller on CLI")
+	save := fs.String("save", "", "where the trained controller is written (default model.rlnn in the run directory, .json for qlearning and tuned controllers other than ai)")
+	resume := fs.String("resume", "", "run (its directory, or its name inside <out>/runs) to continue from its last checkpoint, with its own flags and config")
+	curriculum := fs.Bool("curriculum", false, "train through the Trainer.Curriculum stages, from easy scenarios up to the pad with wind, ignoring --level")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	var run *runs.Run
+	if *resume != "" {
+		locating := true
+		fs.Visit(func(fl *flag.Flag) {
+			locating = locating && (fl.Name == "resume" || fl.Name == "out" || fl.Name == "config")
+		})
+		if !locating {
+			return errors.New("--resume continues a run with its own flags and config, it only takes --out or --config to find it")
+		}
+		out, err := flags.outDir()
+		if err != nil {
+			return err
+		}
+		if run, err = runs.Open(filepath.Join(out, runs.DirName), *resume); err != nil {
+			return err
+		}
+		if run.Summary.Command != "train" {
+			return fmt.Errorf("run %v is not a training run", run.Dir)
+		}
+		if err := fs.Parse(run.Summary.Args); err != nil {
+			return err
+		}
+		flags.config = run.Path(runs.ConfigFile)
+	}
+	opts, err := flags.options()
+	if err != nil {
+		return err
+	}
+	if *trainer == "" {
+		appmanager.StartSimulationDriver(opts)
+		return nil
+	}
+	cfg := flags.effective
+	trainOpts := train.Options{
+		Trainer:            *trainer,
+		Generations:        cfg.Trainer.Generations,
+		Population:         cfg.Trainer.Population,
+		Workers:            cfg.Trainer.Workers,
+		SeedsPerEvaluation: cfg.Trainer.SeedsPerEvaluation,
+		Seed:               cfg.Trainer.Seed,
+		Level:              opts.Level,
+		Limits:             opts.Limits,
+		ModelPath:          *save,
+		CheckpointEvery:    cfg.Trainer.CheckpointEvery,
+		NEAT:               cfg.Trainer.NEAT,
+		CMAES:              cfg.Trainer.CMAES,
+		PPO:                cfg.Trainer.PPO,
+		QLearning:          cfg.Trainer.QLearning,
+		Imitation:          cfg.Trainer.Imitation,
+		Curriculum:         cfg.Trainer.Curriculum,
+		Reward:             cfg.Trainer.Reward,
+	}
+	if *curriculum {
+		trainOpts.Curriculum.Enabled = true
+	}
+	// User flights are recorded as demonstrations next to the landing log, which is where they are cloned from by default
+	if trainOpts.Imitation.Demonstrations == "" {
+		trainOpts.Imitation.Demonstrations = appmanager.DemonstrationsDir(cfg.LandingLogPath())
+	}
+	// Tuned controller settings are saved as a config file and Q-tables as JSON, networks as a model
+	extension := ".rlnn"
+	if *trainer == train.TrainerQLearning {
+		extension = ".json"
+	}
+	if *trainer == train.TrainerCMAES {
+		manager, inputErr := input.CreateInput(opts.InputType)
+		if inputErr {
+			return fmt.Errorf("input %v has not been initalized correctly", input.InputString[opts.InputType])
+		}
+		tunable, ok := manager.(input.Tunable)
+		if !ok {
+			return fmt.Errorf("controller %v has no parameters to tune", flags.controller)
+		}
+		trainOpts.Tunable = tunable
+		if opts.InputType != input.AIInput {
+			extension = ".json"
+		}
+	}
+	if run == nil {
+		if run, err = newRun(cfg, "train", *trainer, args); err != nil {
+			return err
+		}
+	} else {
+		trainOpts.Resume = run.Path(runs.CheckpointsDir, runs.CheckpointFile)
+		if _, err := os.Stat(trainOpts.Resume); err != nil {
+			return fmt.Errorf("run %v has no checkpoint to resume from yet", run.Dir)
+		}
+	}
+	trainOpts.Run = run
+	if trainOpts.ModelPath == "" {
+		trainOpts.ModelPath = run.Path("model" + extension)
+	}
+	run.Summary.Model = trainOpts.ModelPath
+	return run.Close(train.Run(trainOpts))
+}
+
+// newRun creates the directory of a run of command with flags args inside <out>/runs and writes the effective config to it
+func newRun(cfg config.Config, command, name string, args []string) (*runs.Run, error) {
+	run, err := runs.Create(filepath.Join(cfg.Logs.Dir, runs.DirName), command, name, args)
+	if err != nil {
+		return nil, fmt.Errorf("run directory could not be created: %v", err)
+	}
+	if err := cfg.Write(run.Path(runs.ConfigFile)); err != nil {
+		return nil, fmt.Errorf("effective config could not be written: %v", err)
+	}
+	return run, nil
+}
+
+func runEval(cmd *command, args []string) error {
+	fs := newFlagSet(cmd, "[flags]")
+	flags := addFlightFlags(fs, "hardcoded")
+	suite := fs.String("suite", appmanager.SuiteStandard, "seed suite: "+strings.Join(appmanager.SuiteNames, ", "))
+	workers := fs.Int("workers", 0, "flights simulated in parallel, 0 uses every CPU")
+	report := fs.String("report", "", "JSON report path (default report.json in the run directory)")
+	baseline := fs.String("baseline", "", "report to compare against, exits with status 1 on regression")
+	rateTolerance := fs.Float64("tolerance", 0.01, "how much success rate may drop against --baseline")
+	scoreTolerance := fs.Float64("score-tolerance", 0.1, "how much median score may drop against --baseline")
+	telemetry := fs.Bool("telemetry", false, "record telemetry of every flight")
+	logLandings := fs.Bool("log-landings", false, "log every flight to the landing log")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	opts, err := flags.options()
+	if err != nil {
+		return err
+	}
+	if *workers < 0 {
+		return fmt.Errorf("invalid --workers %v, must not be negative", *workers)
+	}
+	run, err := newRun(flags.effective, "eval", strings.ToLower(input.InputString[opts.InputType])+"-"+*suite, args)
+	if err != nil {
+		return err
+	}
+	if *report == "" {
+		*report = run.Path("report.json")
+	}
+	regressed, err := appmanager.StartEvaluation(opts, appmanager.EvalOptions{
+		Suite:          *suite,
+		Workers:        *workers,
+		ReportPath:     *report,
+		BaselinePath:   *baseline,
+		RateTolerance:  *rateTolerance,
+		ScoreTolerance: *scoreTolerance,
+		Telemetry:      *telemetry,
+		LogLandings:    *logLandings,
+		Run:            run,
+	})
+	if err := run.Close(err); err != nil {
+		return err
+	}
+	if regressed {
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runReplay(cmd *command, args []string) error {
+	fs := newFlagSet(cmd, "--file <recording> [flags]")
+	file := fs.String("file", "", "flight recording (a telemetry file) to replay")
+	headless := fs.Bool("headless", false, "only check the recording on CLI, as fast as possible")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		fs.Usage()
+		return errors.New("--file is required")
+	}
+	appmanager.StartReplayDriver(!*headless, *file)
+	return nil
+}
+
+func runStats(cmd *command, args []string) error {
+	fs := newFlagSet(cmd, "[flags]")
+	logPath := fs.String("log", appmanager.DefaultLogPath, "landing log path")
+	from := fs.String("from", "", "only landings from this day (2006-01-02) or time (RFC 3339) on")
+	to := fs.String("to", "", "only landings before this day (2006-01-02) or time (RFC 3339)")
+	seed := fs.Int("seed", 0, "only landings with this seed, 0 matches all")
+	fps := fs.Int("fps", 0, "only landings with this fps, 0 matches all")
+	format := fs.String("format", "table", "output format: table or json")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	var filter appmanager.StatsFilter
+	var err error
+	if filter.From, err = parseDate(*from); err != nil {
+		return fmt.Errorf("invalid --from: %v", err)
+	}
+	if filter.To, err = parseDate(*to); err != nil {
+		return fmt.Errorf("invalid --to: %v", err)
+	}
+	filter.Seed = *seed
+	filter.Fps = *fps
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("invalid --format %q, must be table or json", *format)
+	}
+	return appmanager.PrintStats(*logPath, filter, *format == "json")
+}
+
+func runExport(cmd *command, args []string) error {
+	fs := newFlagSet(cmd, "[flags]")
+	logPath := fs.String("log", appmanager.DefaultLogPath, "landing log path, telemetry is read from next to it")
+	out := fs.String("out", "export", "output directory")
+	format := fs.String("format", appmanager.ExportCSV, "output format: csv or columnar")
+	telemetry := fs.Bool("telemetry", false, "also export every flight's telemetry")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *format != appmanager.ExportCSV && *format != appmanager.ExportColumnar {
+		return fmt.Errorf("invalid --format %q, must be %v or %v", *format, appmanager.ExportCSV, appmanager.ExportColumnar)
+	}
+	return appmanager.Export(*logPath, *out, *format, *telemetry)
+}
+
+func runRuns(cmd *command, args []string) error {
+	fs := newFlagSet(cmd, "[flags] [run...]")
+	out := fs.String("out", config.Default().Logs.Dir, "output directory the runs were written to")
+	format := fs.String("format", "table", "output format of the list: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("invalid --format %q, must be table or json", *format)
+	}
+	root := filepath.Join(*out, runs.DirName)
+	if fs.NArg() == 0 {
+		return runs.List(root, *format == "json")
+	}
+	return runs.Compare(root, fs.Args())
+}
+
+// parseDate accepts a day (2006-01-02) or a full RFC 3339 timestamp, an empty value is the zero time
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/renatobrittoaraujo/rl/helpers"
+	"github.com/renatobrittoaraujo/rl/input"
+	"github.com/renatobrittoaraujo/rl/sim"
+	"github.com/renatobrittoaraujo/rl/train"
+)
+
+// landingLogName is the name of the landing log inside Logs.Dir
+const landingLogName = "landing_logs.jsonl"
+
+// Config holds every tunable parameter of simulations, controllers, trainers and logs
+//
+// A config file is JSON with any subset of these fields, fields it leaves out keep their defaults
+type Config struct {
+	Physics     sim.Physics
+	Vehicle     sim.Vehicle
+	Level       int
+	Limits      sim.Limits
+	Scoring     sim.ScoreWeights
+	Controllers input.Settings
+	Trainer     Trainer
+	Logs        Logs
+}
+
+// Trainer holds settings shared by every trainer
+type Trainer struct {
+	Generations        int   // generations (or epochs) to train for
+	Population         int   // individuals per generation, for population based trainers
+	Workers            int   // episodes simulated in parallel, 0 uses every CPU
+	SeedsPerEvaluation int   // episodes flown to evaluate a controller
+	Seed               int64 // seed of the trainer's random number generator
+	CheckpointEvery    int   // generations between checkpoints of the whole training state, 0 disables them
+	NEAT               train.NEATSettings
+	CMAES              train.CMAESSettings
+	PPO                train.PPOSettings
+	QLearning          train.QLearningSettings
+	Imitation          train.ImitationSettings
+	Curriculum         train.CurriculumSettings
+	Reward             train.RewardSettings
+}
+
+// Logs holds where outputs are written
+type Logs struct {
+	Dir        string // landing log, telemetry and effective configs are written here
+	LandingLog string // landing log path, empty is landing_logs.jsonl inside Dir
+}
+
+// Default returns the config used when nothing else is given
+func Default() Config {
+	return Config{
+		Physics:     sim.Earth,
+		Vehicle:     sim.Falcon9,
+		Level:       sim.LevelFree,
+		Limits:      sim.DefaultLimits,
+		Scoring:     sim.DefaultScoreWeights,
+		Controllers: input.DefaultSettings,
+		Trainer: Trainer{
+			Generations:        100,
+			Population:         50,
+			SeedsPerEvaluation: 20,
+			Seed:               1,
+			CheckpointEvery:    10,
+			NEAT:               train.DefaultNEATSettings,
+			CMAES:              train.DefaultCMAESSettings,
+			PPO:                train.DefaultPPOSettings,
+			QLearning:          train.DefaultQLearningSettings,
+			Imitation:          train.DefaultImitationSettings,
+			Curriculum:         train.DefaultCurriculumSettings,
+			Reward:             train.DefaultRewardSettings,
+		},
+		Logs: Logs{
+			Dir: "logs",
+		},
+	}
+}
+
+// Load merges the config file at path into c, unknown fields are an error so typos do not go unnoticed
+func (c *Config) Load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("config %v could not be parsed: %v", path, err)
+	}
+	return nil
+}
+
+// Write saves c as JSON at path, creating its directory
+func (c Config) Write(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return helpers.WriteFile(path, append(data, '\n'))
+}
+
+// Validate returns an error describing the first invalid value in c
+func (c Config) Validate() error {
+	switch {
+	case c.Physics.Gravity < 0:
+		return errors.New("Physics.Gravity must not be negative")
+	case c.Physics.AngularDamping <= 0 || c.Physics.AngularDamping > 1:
+		return errors.New("Physics.AngularDamping must be in (0, 1]")
+	case c.Vehicle.MaxThrust <= 0:
+		return errors.New("Vehicle.MaxThrust must be positive")
+	case c.Vehicle.DryMass <= 0 || c.Vehicle.WetMass <= c.Vehicle.DryMass:
+		return errors.New("Vehicle.DryMass must be positive and less than Vehicle.WetMass")
+	case c.Vehicle.MaxEngineOnTime <= 0:
+		return errors.New("Vehicle.MaxEngineOnTime must be positive")
+	case c.Vehicle.EngineStarts < 1:
+		return errors.New("Vehicle.EngineStarts must be at least 1 (liftoff)")
+	case !sim.ValidLevel(c.Level):
+		return fmt.Errorf("Level must be from %v to %v", sim.LevelFree, sim.LevelPad)
+	case c.Scoring.MaxVerticalSpeed <= 0 || c.Scoring.MaxHorizontalSpeed <= 0 || c.Scoring.MaxAngularRate <= 0:
+		return errors.New("Scoring.MaxVerticalSpeed, MaxHorizontalSpeed and MaxAngularRate must be positive")
+	case c.Scoring.MinScore() < sim.UnlandedScore:
+		return fmt.Errorf("Scoring.Speed and Scoring.Angle must add up to at most %v (AngularRate counts instead of Angle when larger, with the angular-rate and full scorers), or a crash can score below a flight that never lands", (1-sim.UnlandedScore)/5.0)
+	case c.Limits.MaxFlightTime < 0 || c.Limits.MaxAltitude < 0 || c.Limits.MaxDrift < 0:
+		return errors.New("Limits must not be negative")
+	case c.Controllers.Guidance.Steps < 1 || c.Controllers.Guidance.ReplanInterval <= 0 || c.Controllers.Guidance.MaxFlightTime <= c.Controllers.Guidance.ReplanInterval:
+		return errors.New("Controllers.Guidance.Steps and Controllers.Guidance.ReplanInterval must be positive and Controllers.Guidance.MaxFlightTime above Controllers.Guidance.ReplanInterval")
+	case c.Controllers.Guidance.MaxTilt <= 0 || c.Controllers.Guidance.MaxTilt >= math.Pi/2 || c.Controllers.Guidance.GlideSlope < 0 || c.Controllers.Guidance.GlideSlope >= math.Pi/2:
+		return errors.New("Controllers.Guidance.MaxTilt must be in (0, pi/2) and Controllers.Guidance.GlideSlope in [0, pi/2)")
+	case c.Controllers.MPC.Horizon <= 0 || c.Controllers.MPC.Segments < 1 || c.Controllers.MPC.Samples < 1 || c.Controllers.MPC.Iterations < 1 || c.Controllers.MPC.Elites < 1:
+		return errors.New("Controllers.MPC.Horizon, Segments, Samples, Iterations and Elites must be positive")
+	case c.Trainer.Generations < 0 || c.Trainer.Population < 0 || c.Trainer.Workers < 0 || c.Trainer.SeedsPerEvaluation < 0 || c.Trainer.CheckpointEvery < 0:
+		return errors.New("Trainer settings must not be negative")
+	case c.Trainer.NEAT.CompatibilityThreshold <= 0 || c.Trainer.NEAT.SurvivalRate <= 0 || c.Trainer.NEAT.SurvivalRate > 1:
+		return errors.New("Trainer.NEAT.CompatibilityThreshold must be positive and Trainer.NEAT.SurvivalRate in (0, 1]")
+	case c.Trainer.CMAES.Sigma <= 0 || c.Trainer.CMAES.PopulationSize < 0 || c.Trainer.CMAES.PopulationSize == 1 || c.Trainer.CMAES.Restarts < 0:
+		return errors.New("Trainer.CMAES.Sigma must be positive, Trainer.CMAES.PopulationSize 0 or at least 2 and Trainer.CMAES.Restarts not negative")
+	case c.Trainer.PPO.LearningRate <= 0 || c.Trainer.PPO.Gamma <= 0 || c.Trainer.PPO.Gamma > 1 || c.Trainer.PPO.ClipRange <= 0:
+		return errors.New("Trainer.PPO.LearningRate and Trainer.PPO.ClipRange must be positive and Trainer.PPO.Gamma in (0, 1]")
+	case c.Trainer.QLearning.LearningRate <= 0 || c.Trainer.QLearning.Gamma <= 0 || c.Trainer.QLearning.Gamma > 1 || c.Trainer.QLearning.ThrottleStep <= 0:
+		return errors.New("Trainer.QLearning.LearningRate and Trainer.QLearning.ThrottleStep must be positive and Trainer.QLearning.Gamma in (0, 1]")
+	case c.Trainer.Imitation.LearningRate <= 0 || c.Trainer.Imitation.DAggerIterations < 0 || c.Trainer.Imitation.DAggerEpisodes < 0:
+		return errors.New("Trainer.Imitation.LearningRate must be positive, Trainer.Imitation.DAggerIterations and DAggerEpisodes not negative")
+	case c.Trainer.Reward.Velocity < 0 || c.Trainer.Reward.Attitude < 0 || c.Trainer.Reward.Distance < 0 || c.Trainer.Reward.Fuel < 0 ||
+		c.Trainer.Reward.Ignitions < 0 || c.Trainer.Reward.Contact < 0 || c.Trainer.Reward.Terminal < 0:
+		return errors.New("Trainer.Reward weights must not be negative, costs are already subtracted")
+	case c.Trainer.Curriculum.Window < 1 || len(c.Trainer.Curriculum.Stages) == 0:
+		return errors.New("Trainer.Curriculum needs a Window of at least 1 and at least one stage")
+	case c.Logs.Dir == "" && c.Logs.LandingLog == "":
+		return errors.New("Logs.Dir or Logs.LandingLog must be set")
+	}
+	if _, err := sim.NewScorer(c.Scoring); err != nil {
+		return fmt.Errorf("Scoring.Scorer must be one of %v", strings.Join(sim.Scorers, ", "))
+	}
+	return c.validateCurriculum()
+}
+
+// validateCurriculum returns an error describing the first invalid curriculum stage
+func (c Config) validateCurriculum() error {
+	for i, stage := range c.Trainer.Curriculum.Stages {
+		switch {
+		case !sim.ValidLevel(stage.Level):
+			return fmt.Errorf("Trainer.Curriculum.Stages[%v].Level must be from %v to %v", i, sim.LevelFree, sim.LevelPad)
+		case stage.MaxLean <= 0 || stage.MaxLength <= -1:
+			return fmt.Errorf("Trainer.Curriculum.Stages[%v].MaxLean must be positive and MaxLength above -1, or no seed is flown", i)
+		case stage.Disturbances.Wind < 0 || stage.Disturbances.Gusts < 0 || stage.Disturbances.SensorNoise < 0:
+			return fmt.Errorf("Trainer.Curriculum.Stages[%v].Disturbances must not be negative", i)
+		}
+	}
+	return nil
+}
+
+// Apply makes c the default of every rocket and input created from now on
+func (c Config) Apply() {
+	sim.DefaultPhysics = c.Physics
+	sim.DefaultVehicle = c.Vehicle
+	sim.DefaultScoreWeights = c.Scoring
+	if scorer, err := sim.NewScorer(c.Scoring); err == nil {
+		sim.DefaultScorer = scorer
+	}
+	input.DefaultSettings = c.Controllers
+}
+
+// LandingLogPath returns where landings are logged
+func (c Config) LandingLogPath() string {
+	if c.Logs.LandingLog != "" {
+		return c.Logs.LandingLog
+	}
+	return filepath.Join(c.Logs.Dir, landingLogName)
+}
@@ -0,0 +1,231 @@
+// Package guidance plans fuel-optimal powered descents, in the style of G-FOLD
+// (Açıkmeşe and Ploen, "Convex Programming Approach to Powered Descent Guidance for Mars Landing")
+//
+// The rocket is a point mass in 2D with constant mass, which makes the descent
+// a linear program once the thrust norm constraint is approximated by a polygon
+package guidance
+
+import (
+	"fmt"
+	"math"
+)
+
+// normSides is the amount of half-planes approximating ‖u‖ <= σ inside the tilt cone
+const normSides = 5
+
+// searchScans and searchRefinements are the flight times tried by Optimal, spaced evenly and then by golden section
+const (
+	searchScans       = 6
+	searchRefinements = 6
+)
+
+// State is the position (m) and velocity (m/s) of the rocket
+type State struct {
+	X, Y, VX, VY float64
+}
+
+// Problem is a powered descent towards Target, thrust acceleration u is constant during each of Steps
+type Problem struct {
+	Gravity         float64 // m/s^2
+	MaxAcceleration float64 // m/s^2 of thrust at full throttle
+	MaxTilt         float64 // radians thrust may point away from vertical
+	GlideSlope      float64 // radians above the ground, seen from Target, the rocket must stay above, 0 disables it
+	VerticalTime    float64 // seconds before touchdown thrust must point straight up, so the rocket lands upright
+	DeltaV          float64 // m/s of thrust acceleration the remaining fuel provides, ∫‖u‖dt
+	Target          State   // position and velocity at touchdown
+	Steps           int
+}
+
+// Plan is the thrust acceleration to hold during each step of a descent
+type Plan struct {
+	FlightTime    float64
+	Accelerations [][2]float64 // x and y of the thrust acceleration of each step
+	DeltaV        float64      // ∫‖u‖dt, what the plan minimizes
+}
+
+// Step returns the duration of each step of the plan
+func (p Plan) Step() float64 {
+	return p.FlightTime / float64(len(p.Accelerations))
+}
+
+// At returns where the plan expects the rocket elapsed seconds after leaving from
+func (p Plan) At(from State, elapsed, gravity float64) State {
+	state := from
+	dt := p.Step()
+	for _, u := range p.Accelerations {
+		t := math.Min(dt, elapsed)
+		if t <= 0 {
+			break
+		}
+		ax, ay := u[0], u[1]-gravity
+		state.X += state.VX*t + ax*t*t/2
+		state.Y += state.VY*t + ay*t*t/2
+		state.VX += ax * t
+		state.VY += ay * t
+		elapsed -= t
+	}
+	return state
+}
+
+// Solve returns the plan using the least fuel to reach the target from in exactly flightTime seconds
+//
+// Variables are, per step, the thrust acceleration (x shifted by MaxAcceleration so it is
+// never negative) and its norm σ. Position and velocity are affine in the accelerations,
+// so constraints on them are linear
+func (p Problem) Solve(from State, flightTime float64) (Plan, error) {
+	n := p.Steps
+	if n < 1 || flightTime <= 0 {
+		return Plan{}, fmt.Errorf("descent needs at least 1 step and some flight time")
+	}
+	dt := flightTime / float64(n)
+	amax := p.MaxAcceleration
+	vars := 3 * n
+	ux, uy, sigma := func(k int) int { return 3 * k }, func(k int) int { return 3*k + 1 }, func(k int) int { return 3*k + 2 }
+	lp := &LP{Cost: make([]float64, vars)}
+	upper := func(row []float64, bound float64) {
+		lp.Upper = append(lp.Upper, row)
+		lp.UpperBounds = append(lp.UpperBounds, bound)
+	}
+	equal := func(row []float64, bound float64) {
+		lp.Equal = append(lp.Equal, row)
+		lp.EqualBounds = append(lp.EqualBounds, bound)
+	}
+
+	// position returns coefficients and constant of the x (axis 0) or y (axis 1) position after k steps:
+	// p_k = p_0 + k dt v_0 + Σ_{j<k} (k - j - 1/2) dt² (u_j + g)
+	position := func(k, axis int) ([]float64, float64) {
+		row := make([]float64, vars)
+		p0, v0, g := from.X, from.VX, 0.0
+		if axis == 1 {
+			p0, v0, g = from.Y, from.VY, -p.Gravity
+		}
+		constant := p0 + float64(k)*dt*v0
+		for j := 0; j < k; j++ {
+			weight := (float64(k-j) - 0.5) * dt * dt
+			if axis == 0 {
+				row[ux(j)] = weight
+				constant -= weight * amax
+			} else {
+				row[uy(j)] = weight
+			}
+			constant += weight * g
+		}
+		return row, constant
+	}
+
+	deltaV := make([]float64, vars)
+	for k := 0; k < n; k++ {
+		lp.Cost[sigma(k)] = dt
+		deltaV[sigma(k)] = dt
+		// ‖u‖ <= σ, by half-planes tangent to the circle inside the tilt cone
+		for side := 0; side < normSides; side++ {
+			angle := -p.MaxTilt + 2*p.MaxTilt*float64(side)/float64(normSides-1)
+			row := make([]float64, vars)
+			row[ux(k)], row[uy(k)], row[sigma(k)] = math.Sin(angle), math.Cos(angle), -1
+			upper(row, amax*math.Sin(angle))
+		}
+		// |ux| <= tan(MaxTilt) uy
+		tan := math.Tan(p.MaxTilt)
+		row := make([]float64, vars)
+		row[ux(k)], row[uy(k)] = 1, -tan
+		upper(row, amax)
+		row = make([]float64, vars)
+		row[ux(k)], row[uy(k)] = -1, -tan
+		upper(row, -amax)
+		// σ <= MaxAcceleration
+		row = make([]float64, vars)
+		row[sigma(k)] = 1
+		upper(row, amax)
+		if float64(n-k)*dt <= p.VerticalTime {
+			row = make([]float64, vars)
+			row[ux(k)] = 1
+			equal(row, amax)
+		}
+	}
+	upper(deltaV, p.DeltaV)
+
+	// Y - target.Y >= tan(GlideSlope) |X - target.X| on every step before touchdown
+	if p.GlideSlope > 0 {
+		tan := math.Tan(p.GlideSlope)
+		for k := 1; k < n; k++ {
+			xs, x := position(k, 0)
+			ys, y := position(k, 1)
+			for _, sign := range []float64{1, -1} {
+				row := make([]float64, vars)
+				for i := range row {
+					row[i] = sign*tan*xs[i] - ys[i]
+				}
+				upper(row, y-p.Target.Y-sign*tan*(x-p.Target.X))
+			}
+		}
+	}
+
+	// Touchdown at the target position and velocity
+	for axis := 0; axis < 2; axis++ {
+		row, constant := position(n, axis)
+		target := p.Target.X
+		if axis == 1 {
+			target = p.Target.Y
+		}
+		equal(row, target-constant)
+	}
+	vx, vy := make([]float64, vars), make([]float64, vars)
+	for k := 0; k < n; k++ {
+		vx[ux(k)], vy[uy(k)] = dt, dt
+	}
+	equal(vx, p.Target.VX-from.VX+float64(n)*dt*amax)
+	equal(vy, p.Target.VY-from.VY+float64(n)*dt*p.Gravity)
+
+	x, err := lp.Solve()
+	if err != nil {
+		return Plan{}, err
+	}
+	plan := Plan{FlightTime: flightTime, Accelerations: make([][2]float64, n)}
+	for k := range plan.Accelerations {
+		plan.Accelerations[k] = [2]float64{x[ux(k)] - amax, x[uy(k)]}
+		plan.DeltaV += x[sigma(k)] * dt
+	}
+	return plan, nil
+}
+
+// Optimal returns the plan using the least fuel with a flight time between min and max seconds
+//
+// Fuel is not convex in the flight time, so times are scanned evenly before a golden
+// section search refines the best of them, as G-FOLD does
+func (p Problem) Optimal(from State, min, max float64) (Plan, error) {
+	best, bestTime := Plan{DeltaV: math.Inf(1)}, -1.0
+	try := func(flightTime float64) float64 {
+		plan, err := p.Solve(from, flightTime)
+		if err != nil {
+			return math.Inf(1)
+		}
+		if plan.DeltaV < best.DeltaV {
+			best, bestTime = plan, flightTime
+		}
+		return plan.DeltaV
+	}
+	spacing := (max - min) / (searchScans - 1)
+	for i := 0; i < searchScans; i++ {
+		try(min + spacing*float64(i))
+	}
+	if bestTime < 0 {
+		return Plan{}, ErrInfeasible
+	}
+
+	ratio := (math.Sqrt(5) - 1) / 2
+	a, b := math.Max(min, bestTime-spacing), math.Min(max, bestTime+spacing)
+	c, d := b-ratio*(b-a), a+ratio*(b-a)
+	fc, fd := try(c), try(d)
+	for i := 2; i < searchRefinements; i++ {
+		if fc <= fd {
+			b, d, fd = d, c, fc
+			c = b - ratio*(b-a)
+			fc = try(c)
+		} else {
+			a, c, fc = c, d, fd
+			d = a + ratio*(b-a)
+			fd = try(d)
+		}
+	}
+	return best, nil
+}
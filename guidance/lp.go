@@ -0,0 +1,217 @@
+package guidance
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrInfeasible is returned when no point satisfies every constraint of a linear program
+var ErrInfeasible = errors.New("linear program is infeasible")
+
+// ErrUnbounded is returned when the objective of a linear program can decrease forever
+var ErrUnbounded = errors.New("linear program is unbounded")
+
+// epsilon is the tolerance of every comparison of the simplex method
+const epsilon = 1e-9
+
+// maxPivots bounds the work of a single solve, reaching it is reported as infeasible
+const maxPivots = 20000
+
+// LP is a linear program: minimize Cost·x subject to Upper·x <= UpperBounds,
+// Equal·x = EqualBounds and x >= 0
+type LP struct {
+	Cost        []float64
+	Upper       [][]float64
+	UpperBounds []float64
+	Equal       [][]float64
+	EqualBounds []float64
+}
+
+// tableau is a dense simplex tableau, the last row is the objective and the last column the bounds
+type tableau struct {
+	rows  [][]float64
+	basis []int // variable in the basis of each constraint row
+}
+
+// Solve returns an optimal x using the two-phase simplex method
+func (lp *LP) Solve() ([]float64, error) {
+	n := len(lp.Cost)
+	m := len(lp.Upper) + len(lp.Equal)
+	slacks := len(lp.Upper)
+	// Rows whose slack can not start in the basis (equalities and negative bounds) get an artificial variable
+	artificials := 0
+	for i := 0; i < m; i++ {
+		if i >= len(lp.Upper) || lp.UpperBounds[i] < 0 {
+			artificials++
+		}
+	}
+	columns := n + slacks + artificials
+	t := &tableau{basis: make([]int, m)}
+	objective := make([]float64, columns+1)
+	artificial := n + slacks
+	for i := 0; i < m; i++ {
+		var row []float64
+		var bound float64
+		if i < len(lp.Upper) {
+			row, bound = lp.Upper[i], lp.UpperBounds[i]
+		} else {
+			row, bound = lp.Equal[i-len(lp.Upper)], lp.EqualBounds[i-len(lp.Upper)]
+		}
+		full := make([]float64, columns+1)
+		copy(full, row)
+		if i < len(lp.Upper) {
+			full[n+i] = 1
+		}
+		full[columns] = bound
+		if bound < 0 {
+			for j := range full {
+				full[j] = -full[j]
+			}
+		}
+		if i < len(lp.Upper) && bound >= 0 {
+			t.basis[i] = n + i
+		} else {
+			full[artificial] = 1
+			objective[artificial] = 1
+			t.basis[i] = artificial
+			artificial++
+		}
+		t.rows = append(t.rows, full)
+	}
+
+	// Phase 1 minimizes the sum of artificial variables, a feasible point zeroes it
+	if artificials > 0 {
+		t.rows = append(t.rows, objective)
+		for i := 0; i < m; i++ {
+			t.eliminate(i)
+		}
+		if err := t.optimize(columns); err != nil {
+			return nil, ErrInfeasible
+		}
+		if -t.rows[m][columns] > 1e-7*(1+maxBound(t)) {
+			return nil, ErrInfeasible
+		}
+		// Artificial variables left in the basis at zero are swapped for any other variable of their row
+		for i := 0; i < m; i++ {
+			if t.basis[i] < n+slacks {
+				continue
+			}
+			for j := 0; j < n+slacks; j++ {
+				if math.Abs(t.rows[i][j]) > epsilon {
+					t.pivot(i, j)
+					break
+				}
+			}
+		}
+		t.rows = t.rows[:m]
+	}
+
+	// Phase 2 minimizes the cost, artificial variables are never allowed back in
+	objective = make([]float64, columns+1)
+	copy(objective, lp.Cost)
+	t.rows = append(t.rows, objective)
+	for i := 0; i < m; i++ {
+		t.eliminate(i)
+	}
+	if err := t.optimize(n + slacks); err != nil {
+		return nil, err
+	}
+	x := make([]float64, n)
+	for i, variable := range t.basis {
+		if variable < n {
+			x[variable] = t.rows[i][columns]
+		}
+	}
+	return x, nil
+}
+
+// eliminate zeroes the objective coefficient of the basic variable of row i
+func (t *tableau) eliminate(i int) {
+	objective := t.rows[len(t.rows)-1]
+	factor := objective[t.basis[i]]
+	if factor == 0 {
+		return
+	}
+	for j, value := range t.rows[i] {
+		objective[j] -= factor * value
+	}
+}
+
+// optimize pivots until no variable among the first columns improves the objective
+//
+// Entering variables are picked by the most negative reduced cost, switching to
+// Bland's rule (the first negative one) after a stall so degenerate pivots can not cycle
+func (t *tableau) optimize(columns int) error {
+	m := len(t.rows) - 1
+	objective := t.rows[m]
+	last := len(objective) - 1
+	stalled := 0
+	for pivots := 0; pivots < maxPivots; pivots++ {
+		entering := -1
+		for j := 0; j < columns; j++ {
+			if objective[j] < -epsilon && (entering == -1 || (stalled < 50 && objective[j] < objective[entering])) {
+				entering = j
+				if stalled >= 50 {
+					break
+				}
+			}
+		}
+		if entering == -1 {
+			return nil
+		}
+		leaving := -1
+		ratio := math.Inf(1)
+		for i := 0; i < m; i++ {
+			if t.rows[i][entering] > epsilon {
+				r := t.rows[i][last] / t.rows[i][entering]
+				if r < ratio-epsilon || (r < ratio+epsilon && leaving != -1 && t.basis[i] < t.basis[leaving]) {
+					ratio = r
+					leaving = i
+				}
+			}
+		}
+		if leaving == -1 {
+			return ErrUnbounded
+		}
+		before := objective[last]
+		t.pivot(leaving, entering)
+		if math.Abs(objective[last]-before) < epsilon {
+			stalled++
+		} else {
+			stalled = 0
+		}
+	}
+	return ErrInfeasible
+}
+
+// pivot makes column j basic in row i
+func (t *tableau) pivot(i, j int) {
+	row := t.rows[i]
+	scale := row[j]
+	// Rows stay mostly zeros, so only the columns the pivot row touches are updated
+	var nonzero []int
+	for k := range row {
+		if row[k] != 0 {
+			row[k] /= scale
+			nonzero = append(nonzero, k)
+		}
+	}
+	for r, other := range t.rows {
+		if r == i || other[j] == 0 {
+			continue
+		}
+		factor := other[j]
+		for _, k := range nonzero {
+			other[k] -= factor * row[k]
+		}
+	}
+	t.basis[i] = j
+}
+
+func maxBound(t *tableau) float64 {
+	max := 0.0
+	for _, row := range t.rows[:len(t.rows)-1] {
+		max = math.Max(max, math.Abs(row[len(row)-1]))
+	}
+	return max
+}
@@ -0,0 +1,151 @@
+package guidance
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLPSolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		lp        LP
+		err       error
+		objective float64
+		x         []float64 // expected solution, nil when the optimum is not unique
+	}{
+		{
+			name: "optimal",
+			lp: LP{
+				Cost:        []float64{-1, -1},
+				Upper:       [][]float64{{1, 2}, {3, 1}},
+				UpperBounds: []float64{4, 6},
+			},
+			objective: -2.8,
+			x:         []float64{1.6, 1.2},
+		},
+		{
+			name: "negative bound",
+			lp: LP{
+				Cost:        []float64{1, 2},
+				Upper:       [][]float64{{-1, -1}, {1, 0}},
+				UpperBounds: []float64{-3, 5},
+			},
+			objective: 3,
+			x:         []float64{3, 0},
+		},
+		{
+			name: "infeasible",
+			lp: LP{
+				Cost:        []float64{1},
+				Upper:       [][]float64{{1}, {-1}},
+				UpperBounds: []float64{1, -2},
+			},
+			err: ErrInfeasible,
+		},
+		{
+			name: "infeasible equalities",
+			lp: LP{
+				Cost:        []float64{1, 1},
+				Equal:       [][]float64{{1, 1}, {1, 1}},
+				EqualBounds: []float64{1, 2},
+			},
+			err: ErrInfeasible,
+		},
+		{
+			name: "unbounded",
+			lp: LP{
+				Cost:        []float64{-1, 0},
+				Upper:       [][]float64{{1, -1}},
+				UpperBounds: []float64{1},
+			},
+			err: ErrUnbounded,
+		},
+		{
+			// Beale's example, which cycles forever under the textbook pivoting rule
+			name: "degenerate",
+			lp: LP{
+				Cost: []float64{-0.75, 20, -0.5, 6},
+				Upper: [][]float64{
+					{0.25, -8, -1, 9},
+					{0.5, -12, -0.5, 3},
+					{0, 0, 1, 0},
+				},
+				UpperBounds: []float64{0, 0, 1},
+			},
+			objective: -1.25,
+			x:         []float64{1, 0, 1, 0},
+		},
+		{
+			name: "equalities only",
+			lp: LP{
+				Cost:        []float64{1, 1, 0},
+				Equal:       [][]float64{{1, 1, 0}, {1, -1, 0}, {0, 1, 1}},
+				EqualBounds: []float64{2, 0, 3},
+			},
+			objective: 2,
+			x:         []float64{1, 1, 2},
+		},
+		{
+			name: "redundant equalities",
+			lp: LP{
+				Cost:        []float64{1, 2},
+				Equal:       [][]float64{{1, 1}, {2, 2}},
+				EqualBounds: []float64{1, 2},
+			},
+			objective: 1,
+			x:         []float64{1, 0},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			x, err := test.lp.Solve()
+			if err != test.err {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			if len(x) != len(test.lp.Cost) {
+				t.Fatalf("got %v variables, want %v", len(x), len(test.lp.Cost))
+			}
+			checkFeasible(t, test.lp, x)
+			if objective := dot(test.lp.Cost, x); math.Abs(objective-test.objective) > 1e-6 {
+				t.Errorf("got objective %v, want %v", objective, test.objective)
+			}
+			for i := range test.x {
+				if math.Abs(x[i]-test.x[i]) > 1e-6 {
+					t.Errorf("got x %v, want %v", x, test.x)
+					break
+				}
+			}
+		})
+	}
+}
+
+// checkFeasible fails t if x breaks any constraint of lp
+func checkFeasible(t *testing.T, lp LP, x []float64) {
+	t.Helper()
+	for i, value := range x {
+		if value < -1e-9 {
+			t.Errorf("x[%v] = %v is negative", i, value)
+		}
+	}
+	for i, row := range lp.Upper {
+		if value := dot(row, x); value > lp.UpperBounds[i]+1e-6 {
+			t.Errorf("upper constraint %v: %v > %v", i, value, lp.UpperBounds[i])
+		}
+	}
+	for i, row := range lp.Equal {
+		if value := dot(row, x); math.Abs(value-lp.EqualBounds[i]) > 1e-6 {
+			t.Errorf("equality %v: %v != %v", i, value, lp.EqualBounds[i])
+		}
+	}
+}
+
+func dot(a, b []float64) float64 {
+	total := 0.0
+	for i := range a {
+		total += a[i] * b[i]
+	}
+	return total
+}
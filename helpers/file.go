@@ -0,0 +1,46 @@
+package helpers
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes data to path like ioutil.WriteFile, but see WriteFileWith
+func WriteFile(path string, data []byte) error {
+	return WriteFileWith(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// WriteFileWith writes to path whatever write writes, to a temporary file next to it that is then renamed over path,
+// so an interrupted or failed write never leaves a truncated file behind and readers see either the old or the new file
+func WriteFileWith(path string, write func(w io.Writer) error) error {
+	temp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	// Does nothing once renamed
+	defer os.Remove(temp.Name())
+	writer := bufio.NewWriter(temp)
+	if err := write(writer); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		temp.Close()
+		return err
+	}
+	// Temporary files are only readable by their owner
+	if err := temp.Chmod(0644); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,162 @@
+package input
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/renatobrittoaraujo/rl/nn"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// ObservationLayout names every value ObservationVector returns, in order
+//
+// Models store the layout they were trained with and are refused if it differs,
+// so changing it makes every saved model unusable
+var ObservationLayout = []string{
+	"x", "y", "vx", "vy", "direction", "angular_momentum",
+	"fuel", "thrust", "gimbal", "engine_on", "ignitions",
+}
+
+// ActionLayout names every value ActionFromVector reads, in order
+var ActionLayout = []string{"engine", "thrust", "rcs", "gimbal"}
+
+// unlimitedIgnitionsFeature stands for sim.UnlimitedIgnitions in observation vectors
+const unlimitedIgnitionsFeature = 10
+
+// ObservationVector returns obs as the input of a network, in ObservationLayout order
+func ObservationVector(obs sim.Observation) []float64 {
+	ignitions := float64(obs.EngineStartsRemaining)
+	if obs.EngineStartsRemaining == sim.UnlimitedIgnitions {
+		ignitions = unlimitedIgnitionsFeature
+	}
+	engineOn := 0.0
+	if obs.EngineOn {
+		engineOn = 1
+	}
+	return []float64{
+		float64(obs.Position.X),
+		float64(obs.Position.Y),
+		float64(obs.SpeedVector.X),
+		float64(obs.SpeedVector.Y),
+		float64(obs.Direction),
+		float64(obs.AngularMomentum),
+		float64(obs.Fuel),
+		float64(obs.Thrust),
+		float64(obs.Gimbal),
+		engineOn,
+		ignitions,
+	}
+}
+
+// ActionFromVector returns the action described by a network output in ActionLayout order
+//
+// Outputs are expected in [-1.0, 1.0]: the engine is on when positive, thrust maps to
+// [0.0, 1.0], rcs fires the left jet under -1/3 and the right one above 1/3
+func ActionFromVector(out []float64) sim.Action {
+	action := sim.Action{
+		EngineOn: out[0] > 0,
+		Thrust:   float32((out[1] + 1) / 2),
+		Gimbal:   float32(out[3]),
+	}
+	if out[2] < -1.0/3.0 {
+		action.RCS = sim.RCSLeft
+	} else if out[2] > 1.0/3.0 {
+		action.RCS = sim.RCSRight
+	}
+	return action
+}
+
+// ActionVector returns the network output ActionFromVector turns into a, the target of imitation
+//
+// Thrust and gimbal are clamped like sim.Rocket.Apply does, thrust of an action with the
+// engine off is -1, as if throttled all the way down
+func ActionVector(a sim.Action) []float64 {
+	out := []float64{-1, -1, 0, math.Max(-1, math.Min(1, float64(a.Gimbal)))}
+	if a.EngineOn {
+		out[0], out[1] = 1, 2*math.Max(0, math.Min(1, float64(a.Thrust)))-1
+	}
+	switch a.RCS {
+	case sim.RCSLeft:
+		out[2] = -1
+	case sim.RCSRight:
+		out[2] = 1
+	}
+	return out
+}
+
+// ai flies the rocket with a neural network model
+type ai struct {
+	model *nn.Model
+}
+
+// CreateAI returns a manager that flies with model, model must match ObservationLayout and ActionLayout
+func CreateAI(model *nn.Model) (Manager, error) {
+	if err := model.Compatible(ObservationLayout, ActionLayout); err != nil {
+		return nil, err
+	}
+	return ai{model: model}, nil
+}
+
+// loadAI loads the model at path, warning when it was trained on a different simulation
+func loadAI(path string) (Manager, bool) {
+	if path == "" {
+		fmt.Println("AI input needs a model, set one with --model")
+		return nil, true
+	}
+	model, err := nn.Load(path)
+	if err != nil {
+		fmt.Println(err)
+		return nil, true
+	}
+	manager, err := CreateAI(model)
+	if err != nil {
+		fmt.Println("Model", path, "can not be used:", err)
+		return nil, true
+	}
+	if model.SimVersion != sim.Version {
+		fmt.Printf("Warning: model %v was trained on simulation version %v, this is version %v\n", path, model.SimVersion, sim.Version)
+	}
+	if model.Vehicle != sim.DefaultVehicle || model.Physics != sim.DefaultPhysics {
+		fmt.Printf("Warning: model %v was trained with a different vehicle or physics\n", path)
+	}
+	return manager, false
+}
+
+func (ai ai) Act(obs sim.Observation) sim.Action {
+	return ActionFromVector(ai.model.Forward(ObservationVector(obs)))
+}
+
+// Parameters returns every weight of the network followed by its biases, layer by layer
+func (ai ai) Parameters() []float64 {
+	var params []float64
+	for _, layer := range ai.model.Network.Layers {
+		for _, weights := range layer.Weights {
+			params = append(params, weights...)
+		}
+		params = append(params, layer.Biases...)
+	}
+	return params
+}
+
+func (ai ai) WithParameters(params []float64) Tunable {
+	model := *ai.model
+	model.Network.Layers = make([]nn.Layer, len(ai.model.Network.Layers))
+	for l, layer := range ai.model.Network.Layers {
+		tuned := layer
+		tuned.Weights = make([][]float64, len(layer.Weights))
+		for o := range layer.Weights {
+			tuned.Weights[o] = append([]float64(nil), params[:len(layer.Weights[o])]...)
+			params = params[len(layer.Weights[o]):]
+		}
+		tuned.Biases = append([]float64(nil), params[:len(layer.Biases)]...)
+		params = params[len(layer.Biases):]
+		model.Network.Layers[l] = tuned
+	}
+	ai.model = &model
+	return ai
+}
+
+// Save writes the model, see nn.Model.Save
+func (ai ai) Save(path string) error {
+	return ai.model.Save(path)
+}
@@ -0,0 +1,112 @@
+package input
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/renatobrittoaraujo/rl/helpers"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// autopilot leans against horizontal speed and distance from the pad with rcs and gimbal,
+// and throttles to follow a suicide burn profile down to the ground
+type autopilot struct {
+	settings AutopilotSettings
+}
+
+// autopilotScales is the size of a typical value of each AutopilotSettings field, in order,
+// so every parameter returned by Parameters is around 1
+var autopilotScales = [...]float64{1, 1, 0.01, 0.001, 0.1, 1, 1, 1, 0.01}
+
+// minThrottle keeps the engine lit once ignited when ignitions are limited
+const minThrottle = 0.01
+
+func (autopilot autopilot) Act(obs sim.Observation) sim.Action {
+	tilt, throttle := autopilot.settings.targets(obs)
+	return autopilot.settings.fly(obs, tilt, throttle)
+}
+
+// targets returns the tilt (radians from upright) and throttle the autopilot wants in obs
+func (s AutopilotSettings) targets(obs sim.Observation) (tilt, throttle float64) {
+	vehicle, physics := sim.DefaultVehicle, sim.DefaultPhysics
+	direction := float64(obs.Direction)
+
+	// Leaning to the left (direction above pi/2) pushes the rocket to the left
+	tilt = float64(s.TiltPerSpeed*obs.SpeedVector.X + s.TiltPerDistance*obs.Position.X)
+	tilt = math.Max(-float64(s.MaxTilt), math.Min(float64(s.MaxTilt), tilt))
+
+	// Descend as fast as braking at BurnMargin of the available deceleration still stops the rocket on the ground
+	mass := float64(vehicle.DryMass + obs.Fuel*(vehicle.WetMass-vehicle.DryMass))
+	thrustAcceleration := float64(vehicle.MaxThrust) / mass * math.Max(math.Sin(direction), 0.1)
+	gravity := float64(physics.Gravity)
+	altitude := math.Max(float64(obs.Position.Y)-sim.RocketLenght/2, 0)
+	targetSpeed := -float64(s.TouchdownSpeed)
+	if braking := float64(s.BurnMargin) * (thrustAcceleration - gravity); braking > 0 {
+		targetSpeed -= math.Sqrt(2 * braking * altitude)
+	}
+	throttle = (gravity + float64(s.SpeedGain)*(targetSpeed-float64(obs.SpeedVector.Y))) / thrustAcceleration
+	return tilt, throttle
+}
+
+// fly returns the action leaning tilt radians from upright at throttle
+func (s AutopilotSettings) fly(obs sim.Observation, tilt, throttle float64) sim.Action {
+	action := s.steer(math.Pi/2+tilt, obs)
+	if obs.EngineOn && obs.EngineStartsRemaining != sim.UnlimitedIgnitions {
+		throttle = math.Max(throttle, minThrottle)
+	}
+	action.EngineOn = throttle > 0
+	action.Thrust = float32(throttle)
+	return action
+}
+
+// steer returns the gimbal and rcs that turn the rocket towards direction
+func (s AutopilotSettings) steer(direction float64, obs sim.Observation) sim.Action {
+	command := float64(s.AttitudeGain)*(direction-float64(obs.Direction)) - float64(s.AttitudeDamping)*float64(obs.AngularMomentum)
+	action := sim.Action{Gimbal: float32(command)}
+	if command > float64(s.RCSDeadband) {
+		action.RCS = sim.RCSRight
+	} else if command < -float64(s.RCSDeadband) {
+		action.RCS = sim.RCSLeft
+	}
+	return action
+}
+
+func (autopilot autopilot) Parameters() []float64 {
+	fields := autopilot.settings.fields()
+	params := make([]float64, len(fields))
+	for i, field := range fields {
+		params[i] = float64(*field) / autopilotScales[i]
+	}
+	return params
+}
+
+func (autopilot autopilot) WithParameters(params []float64) Tunable {
+	fields := autopilot.settings.fields()
+	for i, field := range fields {
+		*field = float32(params[i] * autopilotScales[i])
+	}
+	return autopilot
+}
+
+// Save writes a config file that only sets the autopilot settings, to be used with --config
+func (autopilot autopilot) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	config := map[string]map[string]AutopilotSettings{"Controllers": {"Autopilot": autopilot.settings}}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return helpers.WriteFile(path, append(data, '\n'))
+}
+
+// fields returns pointers to every setting, in autopilotScales order
+func (s *AutopilotSettings) fields() []*float32 {
+	return []*float32{
+		&s.AttitudeGain, &s.AttitudeDamping, &s.TiltPerSpeed, &s.TiltPerDistance, &s.MaxTilt,
+		&s.BurnMargin, &s.TouchdownSpeed, &s.SpeedGain, &s.RCSDeadband,
+	}
+}
@@ -0,0 +1,122 @@
+package input
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/renatobrittoaraujo/rl/helpers"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// DemonstrationVersion is the version of the demonstration format, bumped on any incompatible change
+const DemonstrationVersion = 1
+
+// Demonstration is a flight recorded for controllers to imitate
+//
+// Saved as JSONL: the demonstration without its steps, then one step per line
+type Demonstration struct {
+	Version    int
+	Input      string // InputString of who flew it
+	Seed       int
+	Level      int
+	Outcome    string // sim.Outcome of the flight
+	Score      float32
+	SimVersion int
+	Vehicle    sim.Vehicle
+	Physics    sim.Physics
+	Steps      []DemonstrationStep `json:"-"`
+}
+
+// DemonstrationStep is what the controller saw on a tick after ascension and the action that was applied
+type DemonstrationStep struct {
+	Observation sim.Observation
+	Action      sim.Action
+}
+
+// NewDemonstration starts an empty demonstration of a flight on the current sim defaults
+func NewDemonstration(inputType, seed, level int) *Demonstration {
+	return &Demonstration{
+		Version:    DemonstrationVersion,
+		Input:      InputString[inputType],
+		Seed:       seed,
+		Level:      level,
+		SimVersion: sim.Version,
+		Vehicle:    sim.DefaultVehicle,
+		Physics:    sim.DefaultPhysics,
+	}
+}
+
+// Save writes the demonstration to path, creating its directory
+func (d *Demonstration) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return helpers.WriteFileWith(path, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		if err := encoder.Encode(d); err != nil {
+			return err
+		}
+		for _, step := range d.Steps {
+			if err := encoder.Encode(step); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// LoadDemonstration reads a demonstration saved by Demonstration.Save
+func LoadDemonstration(path string) (*Demonstration, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	d := &Demonstration{}
+	if err := decoder.Decode(d); err != nil {
+		return nil, fmt.Errorf("demonstration %v could not be read: %v", path, err)
+	}
+	if d.Version != DemonstrationVersion {
+		return nil, fmt.Errorf("demonstration %v has version %v, expected %v", path, d.Version, DemonstrationVersion)
+	}
+	for decoder.More() {
+		var step DemonstrationStep
+		if err := decoder.Decode(&step); err != nil {
+			return nil, fmt.Errorf("demonstration %v could not be read: %v", path, err)
+		}
+		d.Steps = append(d.Steps, step)
+	}
+	return d, nil
+}
+
+// LoadDemonstrations reads every demonstration in dir, sorted by file name
+func LoadDemonstrations(dir string) ([]*Demonstration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".jsonl") {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Strings(names)
+	demonstrations := make([]*Demonstration, 0, len(names))
+	for _, name := range names {
+		d, err := LoadDemonstration(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		demonstrations = append(demonstrations, d)
+	}
+	return demonstrations, nil
+}
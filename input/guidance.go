@@ -0,0 +1,177 @@
+package input
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/renatobrittoaraujo/rl/guidance"
+	"github.com/renatobrittoaraujo/rl/helpers"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// minIgnitionThrottle is the least planned throttle that ignites the engine, smaller
+// corrections are left to later plans instead of spending an ignition on them
+const minIgnitionThrottle = 0.05
+
+// coastAcceleration is the thrust acceleration (m/s^2) below which a plan step is coasting
+const coastAcceleration = 0.5
+
+// guidanceScales is the size of a typical value of every field returned by GuidanceSettings.fields,
+// so every parameter returned by Parameters is around 1
+var guidanceScales = [...]float64{0.1, 0.01, 1, 1, 0.1, 0.01}
+
+// gfold flies fuel-optimal powered descent plans to the pad (see package guidance),
+// planning again every ReplanInterval from wherever the rocket actually is
+//
+// It points along the planned thrust with the autopilot's attitude control
+// and flies the autopilot when no plan reaches the pad
+type gfold struct {
+	settings GuidanceSettings
+	fallback autopilot
+	episode  *sim.Episode // flight the current plan belongs to
+	plan     guidance.Plan
+	from     guidance.State // where the current plan started
+	planned  bool
+	ticks    int // ticks since the current plan started
+	attempt  int // ticks since planning was last attempted, -1 before the first attempt
+}
+
+func createGfold(settings GuidanceSettings, pilot AutopilotSettings) *gfold {
+	return &gfold{settings: settings, fallback: autopilot{settings: pilot}, attempt: -1}
+}
+
+// Watch forgets the plan of the last flight when a new one starts
+func (g *gfold) Watch(e *sim.Episode) {
+	if e != g.episode {
+		g.plan, g.from, g.planned, g.ticks, g.attempt = guidance.Plan{}, guidance.State{}, false, 0, -1
+	}
+	g.episode = e
+}
+
+func (g *gfold) Act(obs sim.Observation) sim.Action {
+	s := g.settings
+	elapsed := float64(g.ticks) * sim.TickDuration
+	remaining := g.plan.FlightTime - elapsed
+	sinceAttempt := float64(g.attempt) * sim.TickDuration
+	if g.attempt < 0 || (sinceAttempt >= float64(s.ReplanInterval) && (!g.planned || remaining > 2*float64(s.ReplanInterval))) {
+		g.replan(obs, remaining)
+		g.attempt = 0
+		elapsed = float64(g.ticks) * sim.TickDuration
+	}
+	g.ticks++
+	g.attempt++
+	if !g.planned || elapsed >= g.plan.FlightTime {
+		return g.fallback.Act(obs)
+	}
+
+	// Drift from the plan is corrected on top of the planned thrust
+	step := int(elapsed / g.plan.Step())
+	want := g.plan.At(g.from, elapsed, float64(sim.DefaultPhysics.Gravity))
+	thrust := g.plan.Accelerations[step]
+	thrust[0] += float64(s.VelocityGain)*(want.VX-float64(obs.SpeedVector.X)) + float64(s.PositionGain)*(want.X-float64(obs.Position.X))
+	thrust[1] += float64(s.VelocityGain)*(want.VY-float64(obs.SpeedVector.Y)) + float64(s.PositionGain)*(want.Y-float64(obs.Position.Y))
+	// While coasting point where the next burn will push
+	aim := thrust
+	for k := step; k < len(g.plan.Accelerations) && math.Hypot(aim[0], aim[1]) < coastAcceleration; k++ {
+		aim = g.plan.Accelerations[k]
+	}
+	direction := math.Pi / 2
+	if math.Hypot(aim[0], aim[1]) >= coastAcceleration {
+		tilt := math.Atan2(aim[1], aim[0]) - math.Pi/2
+		direction += math.Max(-float64(s.MaxTilt), math.Min(float64(s.MaxTilt), tilt))
+	}
+	action := g.fallback.settings.steer(direction, obs)
+
+	// Only the part of the planned thrust along where the rocket points can be delivered
+	actual := float64(obs.Direction)
+	throttle := (thrust[0]*math.Cos(actual) + thrust[1]*math.Sin(actual)) / maxAcceleration(obs)
+	if obs.EngineOn && obs.EngineStartsRemaining != sim.UnlimitedIgnitions {
+		throttle = math.Max(throttle, minThrottle)
+	} else if !obs.EngineOn && throttle < minIgnitionThrottle {
+		throttle = 0
+	}
+	action.EngineOn = throttle > 0
+	action.Thrust = float32(throttle)
+	return action
+}
+
+// replan solves a new descent from obs, keeping the current plan if none is found
+//
+// The first plan searches every flight time up to MaxFlightTime, later ones stay
+// around the time remaining in the current plan
+func (g *gfold) replan(obs sim.Observation, remaining float64) {
+	s := g.settings
+	vehicle, physics := sim.DefaultVehicle, sim.DefaultPhysics
+	mass := float64(vehicle.DryMass + obs.Fuel*(vehicle.WetMass-vehicle.DryMass))
+	exhaustSpeed := float64(vehicle.MaxThrust * vehicle.MaxEngineOnTime / (vehicle.WetMass - vehicle.DryMass))
+	problem := guidance.Problem{
+		Gravity:         float64(physics.Gravity),
+		MaxAcceleration: maxAcceleration(obs),
+		MaxTilt:         float64(s.MaxTilt),
+		GlideSlope:      float64(s.GlideSlope),
+		VerticalTime:    float64(s.VerticalTime),
+		DeltaV:          exhaustSpeed * math.Log(mass/float64(vehicle.DryMass)),
+		Target:          guidance.State{X: 0, Y: sim.RocketLenght / 2, VY: -float64(s.TouchdownSpeed)},
+		Steps:           s.Steps,
+	}
+	from := guidance.State{
+		X:  float64(obs.Position.X),
+		Y:  float64(obs.Position.Y),
+		VX: float64(obs.SpeedVector.X),
+		VY: float64(obs.SpeedVector.Y),
+	}
+	min, max := float64(s.ReplanInterval), float64(s.MaxFlightTime)
+	if g.planned {
+		min, max = remaining/2, remaining*3/2
+	}
+	plan, err := problem.Optimal(from, min, max)
+	if err != nil {
+		return
+	}
+	g.plan, g.from, g.planned, g.ticks = plan, from, true, 0
+}
+
+// maxAcceleration returns the thrust acceleration at full throttle, in m/s^2
+func maxAcceleration(obs sim.Observation) float64 {
+	vehicle := sim.DefaultVehicle
+	return float64(vehicle.MaxThrust / (vehicle.DryMass + obs.Fuel*(vehicle.WetMass-vehicle.DryMass)))
+}
+
+func (g *gfold) Parameters() []float64 {
+	fields := g.settings.fields()
+	params := make([]float64, len(fields))
+	for i, field := range fields {
+		params[i] = float64(*field) / guidanceScales[i]
+	}
+	return params
+}
+
+func (g *gfold) WithParameters(params []float64) Tunable {
+	settings := g.settings
+	for i, field := range settings.fields() {
+		*field = float32(params[i] * guidanceScales[i])
+	}
+	return createGfold(settings, g.fallback.settings)
+}
+
+// Save writes a config file that only sets the guidance settings, to be used with --config
+func (g *gfold) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	config := map[string]map[string]GuidanceSettings{"Controllers": {"Guidance": g.settings}}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return helpers.WriteFile(path, append(data, '\n'))
+}
+
+// fields returns pointers to the settings that shape the flight, in guidanceScales order
+//
+// Steps, ReplanInterval and MaxFlightTime only trade planning time for plan quality and are left out
+func (s *GuidanceSettings) fields() []*float32 {
+	return []*float32{&s.MaxTilt, &s.GlideSlope, &s.VerticalTime, &s.TouchdownSpeed, &s.VelocityGain, &s.PositionGain}
+}
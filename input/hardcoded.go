@@ -6,8 +6,6 @@ import (
 
 type hardcoded struct{}
 
-var thrust float32 = 0
-
-func (hardcoded hardcoded) UpdateSim(rocket *sim.Rocket) {
-	rocket.SetThrust(1)
+func (hardcoded hardcoded) Act(obs sim.Observation) sim.Action {
+	return sim.Action{EngineOn: true, Thrust: 1}
 }
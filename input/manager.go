@@ -1,6 +1,10 @@
 package input
 
-import "github.com/renatobrittoaraujo/rl/sim"
+import (
+	"strings"
+
+	"github.com/renatobrittoaraujo/rl/sim"
+)
 
 const (
 	// UserInput is a signal to Input Package that the input for current program is from the user
@@ -9,30 +13,166 @@ const (
 	AIInput
 	// HardcodedInput is a signal to Input Package that the input for current program is from a hardcoded algorithm
 	HardcodedInput
+	// QLearningInput is a signal to Input Package that the input for current program is from a Q-table
+	QLearningInput
+	// GuidanceInput is a signal to Input Package that the input for current program is from a powered descent guidance
+	GuidanceInput
+	// MPCInput is a signal to Input Package that the input for current program is from a model predictive controller
+	MPCInput
+	// AutopilotInput is a signal to Input Package that the input for current program is from a suicide burn autopilot
+	AutopilotInput
 )
 
 // InputString is the name of input type for a given input value
-var InputString [3]string = [3]string{"User", "AI", "Hardcoded"}
+var InputString [7]string = [7]string{"User", "AI", "Hardcoded", "QLearning", "Guidance", "MPC", "Autopilot"}
+
+// Settings holds the hyperparameters of every input
+type Settings struct {
+	User      UserSettings
+	AI        AISettings
+	QLearning QLearningSettings
+	Guidance  GuidanceSettings
+	MPC       MPCSettings
+	Autopilot AutopilotSettings
+}
+
+// UserSettings holds the hyperparameters of user input
+type UserSettings struct {
+	ThrustChangePerTick float32 // percentage of thrust added or removed every tick a key is pressed
+}
+
+// AISettings holds the hyperparameters of ai input
+type AISettings struct {
+	ModelPath string // model file, JSON or binary (see nn.Model.Save)
+}
+
+// QLearningSettings holds the hyperparameters of qlearning input
+type QLearningSettings struct {
+	TablePath string // Q-table file, see QTable.Save
+}
+
+// GuidanceSettings holds the hyperparameters of the powered descent guidance
+type GuidanceSettings struct {
+	Steps          int     // steps every plan is divided in, more are slower to solve
+	ReplanInterval float32 // seconds between plans
+	MaxTilt        float32 // radians planned thrust leans from vertical at most
+	GlideSlope     float32 // radians above the ground, seen from the pad, the rocket stays above, 0 disables it
+	VerticalTime   float32 // seconds before touchdown planned thrust points straight up
+	TouchdownSpeed float32 // m/s of descent planned for touchdown
+	MaxFlightTime  float32 // seconds the first plan may take to reach the pad
+	VelocityGain   float32 // m/s^2 of thrust added per m/s away from the planned velocity
+	PositionGain   float32 // m/s^2 of thrust added per meter away from the planned position
+}
+
+// MPCSettings holds the hyperparameters of the model predictive controller
+type MPCSettings struct {
+	Horizon    float32 // seconds of flight every sampled sequence of corrections covers
+	Segments   int     // (throttle, tilt) corrections in a sequence, a new decision is made every Horizon/Segments seconds
+	Samples    int     // sequences flown per iteration
+	Iterations int     // cross-entropy iterations per decision
+	Elites     int     // best sequences the next iteration samples around
+	Seed       int64   // seed of the sampling noise, the same seed flies the same flight the same way
+}
+
+// AutopilotSettings holds the gains and margins of the autopilot
+type AutopilotSettings struct {
+	AttitudeGain    float32 // rcs and gimbal command per radian away from the target direction
+	AttitudeDamping float32 // rcs and gimbal command against each rad/s of rotation
+	TiltPerSpeed    float32 // radians leaned against each m/s of horizontal speed
+	TiltPerDistance float32 // radians leaned towards the pad for each meter away from it
+	MaxTilt         float32 // radians the autopilot leans at most
+	BurnMargin      float32 // fraction of the available deceleration the suicide burn plans with, lower burns earlier
+	TouchdownSpeed  float32 // m/s of descent aimed for at touchdown
+	SpeedGain       float32 // throttle correction per m/s away from the suicide burn profile, per m/s^2 of thrust
+	RCSDeadband     float32 // rcs jets fire only for commands stronger than this
+}
+
+// DefaultSettings are the hyperparameters of inputs created by CreateInput
+var DefaultSettings = Settings{
+	User: UserSettings{
+		ThrustChangePerTick: 0.015,
+	},
+	Guidance: GuidanceSettings{
+		Steps:          20,
+		ReplanInterval: 1,
+		MaxTilt:        0.3,
+		GlideSlope:     0.05,
+		VerticalTime:   3,
+		TouchdownSpeed: 1,
+		MaxFlightTime:  120,
+		VelocityGain:   0.5,
+		PositionGain:   0.05,
+	},
+	MPC: MPCSettings{
+		Horizon:    8,
+		Segments:   8,
+		Samples:    32,
+		Iterations: 3,
+		Elites:     6,
+		Seed:       1,
+	},
+	Autopilot: AutopilotSettings{
+		AttitudeGain:    1,
+		AttitudeDamping: 1,
+		TiltPerSpeed:    0.01,
+		TiltPerDistance: 0.001,
+		MaxTilt:         0.3,
+		BurnMargin:      0.7,
+		TouchdownSpeed:  2,
+		SpeedGain:       1,
+		RCSDeadband:     0.05,
+	},
+}
+
+// InputFromName returns the input type named name (case insensitive), ok is false if there is none
+func InputFromName(name string) (inputType int, ok bool) {
+	for inputType, inputName := range InputString {
+		if strings.EqualFold(name, inputName) {
+			return inputType, true
+		}
+	}
+	return 0, false
+}
 
 // Manager is a interface that allows for easy interaction with input type
+//
+// A manager never touches the rocket, it receives a read-only observation
+// every tick and returns the action it wants, which is applied by the caller
 type Manager interface {
-	UpdateSim(*sim.Rocket)
+	Act(sim.Observation) sim.Action
+}
+
+// Tunable is a controller whose behaviour is set by a vector of parameters, which optimizers tune
+//
+// Every parameter should have a similar scale (around 1), optimizers search all of them with the same step size
+type Tunable interface {
+	Manager
+	// Parameters returns the current parameters
+	Parameters() []float64
+	// WithParameters returns a copy of the controller flying with params
+	WithParameters(params []float64) Tunable
+	// Save writes the controller to path in a format it can be created from again
+	Save(path string) error
 }
 
 // CreateInput returns a struct that follows input.Manager interface
 func CreateInput(inputType int) (Manager, bool) {
 	switch inputType {
 	case UserInput:
-		return user{}, false
-	// case AIInput:
-	// 	return ai{}
-	// case HardcodedInput:
-	// 	return hardcoded{}
+		return &user{settings: DefaultSettings.User}, false
+	case AIInput:
+		return loadAI(DefaultSettings.AI.ModelPath)
+	case HardcodedInput:
+		return hardcoded{}, false
+	case QLearningInput:
+		return loadQLearning(DefaultSettings.QLearning.TablePath)
+	case GuidanceInput:
+		return createGfold(DefaultSettings.Guidance, DefaultSettings.Autopilot), false
+	case MPCInput:
+		return createMPC(DefaultSettings.MPC, DefaultSettings.Autopilot), false
+	case AutopilotInput:
+		return autopilot{settings: DefaultSettings.Autopilot}, false
 	default:
 		return nil, true
 	}
 }
-
-// type ai struct{}
-
-// type hardcoded struct{}
@@ -0,0 +1,250 @@
+package input
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/renatobrittoaraujo/rl/helpers"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// Spread of the first samples of each segment's throttle and tilt (radians) corrections
+const (
+	mpcThrottleSpread = 0.2
+	mpcTiltSpread     = 0.1
+)
+
+// mpcMissedPadCost is added to touchdowns away from the pad, as much as 5 points of landing score
+const mpcMissedPadCost = 50
+
+// mpcMaxPrediction is how many seconds a prediction flies at most before giving up on reaching the ground
+const mpcMaxPrediction = 120
+
+// mpcAirborneCost is the cost of a prediction that never reached the ground, as bad as the worst crash
+const mpcAirborneCost = 90
+
+// mpc is a model predictive controller that uses the simulation itself as model
+//
+// It flies the autopilot with corrections to its throttle and tilt. Every segment it
+// samples sequences of (throttle, tilt) corrections, one per segment of the horizon, flies
+// each on a clone of the live episode (then lets the uncorrected autopilot finish the landing) and
+// keeps the Elites with the lowest predicted cost to sample around them again (the
+// cross-entropy method). The first correction of the best sequence is flown until the next
+// decision, and the mean is shifted by a segment to start the next decision from
+type mpc struct {
+	settings  MPCSettings
+	autopilot autopilot
+	episode   *sim.Episode // live episode, seen right before every Act
+	rng       *rand.Rand
+	mean      []float64 // throttle and tilt corrections of every segment
+	best      []float64
+	ticks     int
+}
+
+func createMPC(settings MPCSettings, pilot AutopilotSettings) *mpc {
+	return &mpc{
+		settings:  settings,
+		autopilot: autopilot{settings: pilot},
+		rng:       rand.New(rand.NewSource(settings.Seed)),
+	}
+}
+
+// Watch keeps the live episode that predictions clone
+//
+// A new episode starts from a fresh search and sampling noise, so the same seed always flies the same flight
+func (m *mpc) Watch(e *sim.Episode) {
+	if e != m.episode {
+		m.rng = rand.New(rand.NewSource(m.settings.Seed))
+		m.mean, m.best, m.ticks = nil, nil, 0
+	}
+	m.episode = e
+}
+
+// Act flies the first correction of the best sequence, deciding again every segment
+//
+// Flown outside of a sim.Episode there is nothing to predict with, so the autopilot flies uncorrected
+func (m *mpc) Act(obs sim.Observation) sim.Action {
+	if m.episode == nil {
+		return m.autopilot.Act(obs)
+	}
+	if m.ticks%m.segmentTicks() == 0 {
+		m.decide(obs)
+	}
+	m.ticks++
+	return m.control(obs, m.best[0], m.best[1])
+}
+
+// segmentTicks returns how long every pair of a sequence is held
+func (m *mpc) segmentTicks() int {
+	ticks := int(math.Round(float64(m.settings.Horizon) / sim.TickDuration / float64(m.settings.Segments)))
+	if ticks < 1 {
+		return 1
+	}
+	return ticks
+}
+
+// decide searches the best sequence from obs with the cross-entropy method
+func (m *mpc) decide(obs sim.Observation) {
+	s := m.settings
+	n := 2 * s.Segments
+	if m.mean == nil {
+		m.mean = make([]float64, n)
+	} else {
+		copy(m.mean, m.mean[2:])
+	}
+	std := make([]float64, n)
+	for i := 0; i < n; i += 2 {
+		std[i], std[i+1] = mpcThrottleSpread, mpcTiltSpread
+	}
+
+	start := m.episode.Clone()
+	samples := make([][]float64, s.Samples)
+	costs := make([]float64, s.Samples)
+	order := make([]int, s.Samples)
+	bestCost := math.Inf(1)
+	for iteration := 0; iteration < s.Iterations; iteration++ {
+		for k := range samples {
+			sample := make([]float64, n)
+			for i := range sample {
+				// The first sample is always the mean, so a good plan is never lost to noise
+				if k > 0 {
+					sample[i] = m.mean[i] + std[i]*m.rng.NormFloat64()
+				} else {
+					sample[i] = m.mean[i]
+				}
+			}
+			m.clamp(sample)
+			samples[k] = sample
+			costs[k] = m.predict(start, sample)
+			order[k] = k
+		}
+		sort.SliceStable(order, func(i, j int) bool { return costs[order[i]] < costs[order[j]] })
+		if costs[order[0]] < bestCost {
+			bestCost = costs[order[0]]
+			m.best = samples[order[0]]
+		}
+		// Next samples are drawn around the elites
+		elites := s.Elites
+		if elites > s.Samples {
+			elites = s.Samples
+		}
+		for i := range m.mean {
+			mean, variance := 0.0, 0.0
+			for _, k := range order[:elites] {
+				mean += samples[k][i]
+			}
+			mean /= float64(elites)
+			for _, k := range order[:elites] {
+				variance += (samples[k][i] - mean) * (samples[k][i] - mean)
+			}
+			m.mean[i], std[i] = mean, math.Sqrt(variance/float64(elites))
+		}
+	}
+	copy(m.mean, m.best)
+}
+
+// clamp keeps every throttle correction in [-1.0, 1.0] and every tilt correction within the autopilot's MaxTilt
+func (m *mpc) clamp(sequence []float64) {
+	maxTilt := float64(m.autopilot.settings.MaxTilt)
+	for i := 0; i < len(sequence); i += 2 {
+		sequence[i] = math.Max(-1, math.Min(1, sequence[i]))
+		sequence[i+1] = math.Max(-maxTilt, math.Min(maxTilt, sequence[i+1]))
+	}
+}
+
+// control returns the autopilot's action corrected by throttle and tilt, tilt staying within MaxTilt
+func (m *mpc) control(obs sim.Observation, throttle, tilt float64) sim.Action {
+	s := m.autopilot.settings
+	targetTilt, targetThrottle := s.targets(obs)
+	tilt = math.Max(-float64(s.MaxTilt), math.Min(float64(s.MaxTilt), targetTilt+tilt))
+	// The autopilot asks for throttles far outside [0.0, 1.0], which no correction could undo
+	targetThrottle = math.Max(0, math.Min(1, targetThrottle))
+	return s.fly(obs, tilt, targetThrottle+throttle)
+}
+
+// correction is the autopilot with a throttle and tilt correction, as a sim.Controller
+type correction struct {
+	m        *mpc
+	throttle float64
+	tilt     float64
+}
+
+func (c correction) Act(obs sim.Observation) sim.Action {
+	return c.m.control(obs, c.throttle, c.tilt)
+}
+
+// predict flies sequence on a clone of start and returns its cost, lower is better
+//
+// After the sequence the autopilot flies the clone down, so every sequence is
+// judged by a whole landing: the negated landing score, the distance to the pad (if the
+// rocket has one) and the fuel burnt. Flights still in the air after mpcMaxPrediction
+// seconds, or ended by the episode's limits, cost mpcAirborneCost
+func (m *mpc) predict(start *sim.Episode, sequence []float64) float64 {
+	e := start.Clone()
+	ticks := m.segmentTicks()
+	fuel := float64(e.Rocket.FuelPercentage())
+	cost := func() float64 {
+		r := e.Rocket
+		if !e.Outcome.Landed() {
+			return mpcAirborneCost + padCost(r)
+		}
+		return -10*float64(sim.LandingScore(r)) + padCost(r) + fuel - float64(r.FuelPercentage())
+	}
+	for i := 0; i < len(sequence); i += 2 {
+		for tick := 0; tick < ticks; tick++ {
+			if _, done := e.Step(correction{m, sequence[i], sequence[i+1]}); done {
+				return cost()
+			}
+		}
+	}
+	for tick := 0; tick < mpcMaxPrediction/sim.TickDuration; tick++ {
+		if _, done := e.Step(m.autopilot); done {
+			return cost()
+		}
+	}
+	return mpcAirborneCost + padCost(e.Rocket)
+}
+
+// padCost grows with the distance from r to its landing pad, jumping by mpcMissedPadCost outside of it, 0 without a pad
+func padCost(r *sim.Rocket) float64 {
+	if r.LandingPad == nil {
+		return 0
+	}
+	pad := r.LandingPad
+	center, half := float64(pad.MinX+pad.MaxX)/2, float64(pad.MaxX-pad.MinX)/2
+	distance := (float64(r.Position.X) - center) / half
+	cost := 10 * distance * distance
+	if math.Abs(distance) > 1 {
+		cost += mpcMissedPadCost
+	}
+	return cost
+}
+
+// Parameters returns the parameters of the autopilot mpc corrects, the search itself is not tuned
+func (m *mpc) Parameters() []float64 {
+	return m.autopilot.Parameters()
+}
+
+func (m *mpc) WithParameters(params []float64) Tunable {
+	pilot := m.autopilot.WithParameters(params).(autopilot)
+	return createMPC(m.settings, pilot.settings)
+}
+
+// Save writes a config file that sets the mpc settings and the autopilot settings it flies with, to be used with --config
+//
+// The autopilot settings are shared with the autopilot controller
+func (m *mpc) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	config := map[string]map[string]interface{}{"Controllers": {"MPC": m.settings, "Autopilot": m.autopilot.settings}}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return helpers.WriteFile(path, append(data, '\n'))
+}
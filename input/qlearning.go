@@ -0,0 +1,196 @@
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/renatobrittoaraujo/rl/helpers"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// QTableVersion is the version of the Q-table format, bumped on any incompatible change
+const QTableVersion = 1
+
+// Discrete actions of a Q-table, every throttle change combined with every rcs jet
+const (
+	throttleHold = iota
+	throttleUp
+	throttleDown
+	throttleActions
+)
+
+// QActions is the amount of discrete actions, an action is throttleAction*3 + rcs jet (see sim.RCSNone)
+const QActions = throttleActions * 3
+
+// QTable holds the value of every discrete action in every discrete state
+//
+// States are observations discretized by bin edges, an observation falls in the bin of
+// the first edge it is below (or in the last bin). The current throttle is part of the
+// state as throttle actions are relative to it
+type QTable struct {
+	Version          int
+	AltitudeBins     []float64 // meters above ground
+	VerticalSpeed    []float64 // m/s
+	HorizontalSpeed  []float64 // m/s
+	AngleBins        []float64 // radians from upright, positive leaning left
+	AngularRateBins  []float64 // rad/s
+	ThrottleBins     []float64 // [0.0, 1.0]
+	ThrottleStep     float32   // thrust added or removed by throttle up and down
+	TicksPerDecision int       // ticks every decision is held for
+	Values           [][]float64
+}
+
+// NewQTable returns a table of zeros with the default bins
+func NewQTable(throttleStep float32, ticksPerDecision int) *QTable {
+	table := &QTable{
+		Version:          QTableVersion,
+		AltitudeBins:     []float64{50, 150, 400, 1000, 2500, 6000, 15000},
+		VerticalSpeed:    []float64{-150, -80, -40, -20, -10, -4, 0, 5},
+		HorizontalSpeed:  []float64{-20, -5, 0, 5, 20},
+		AngleBins:        []float64{-0.4, -0.15, -0.05, 0.05, 0.15, 0.4},
+		AngularRateBins:  []float64{-0.05, -0.01, 0.01, 0.05},
+		ThrottleBins:     []float64{0.01, 0.35, 0.7},
+		ThrottleStep:     throttleStep,
+		TicksPerDecision: ticksPerDecision,
+	}
+	table.Values = make([][]float64, table.states())
+	for s := range table.Values {
+		table.Values[s] = make([]float64, QActions)
+	}
+	return table
+}
+
+func (t *QTable) bins() [][]float64 {
+	return [][]float64{t.AltitudeBins, t.VerticalSpeed, t.HorizontalSpeed, t.AngleBins, t.AngularRateBins, t.ThrottleBins}
+}
+
+func (t *QTable) states() int {
+	states := 1
+	for _, edges := range t.bins() {
+		states *= len(edges) + 1
+	}
+	return states
+}
+
+// State returns the discrete state of obs
+func (t *QTable) State(obs sim.Observation) int {
+	values := []float64{
+		float64(obs.Position.Y) - sim.RocketLenght/2,
+		float64(obs.SpeedVector.Y),
+		float64(obs.SpeedVector.X),
+		float64(obs.Direction) - math.Pi/2,
+		float64(obs.AngularMomentum),
+		float64(obs.Thrust),
+	}
+	state := 0
+	for i, edges := range t.bins() {
+		bin := len(edges)
+		for b, edge := range edges {
+			if values[i] < edge {
+				bin = b
+				break
+			}
+		}
+		state = state*(len(edges)+1) + bin
+	}
+	return state
+}
+
+// Best returns the action with the highest value in state, the first one on ties
+func (t *QTable) Best(state int) int {
+	best := 0
+	for a, value := range t.Values[state] {
+		if value > t.Values[state][best] {
+			best = a
+		}
+	}
+	return best
+}
+
+// Decide returns the sim action to hold for TicksPerDecision ticks when taking discrete action in obs
+func (t *QTable) Decide(obs sim.Observation, action int) sim.Action {
+	thrust := obs.Thrust
+	switch action / 3 {
+	case throttleUp:
+		thrust += t.ThrottleStep
+	case throttleDown:
+		thrust -= t.ThrottleStep
+	}
+	if thrust < 0 {
+		thrust = 0
+	}
+	return sim.Action{
+		EngineOn: thrust > 0,
+		Thrust:   thrust,
+		RCS:      action % 3,
+		Gimbal:   0,
+	}
+}
+
+// Save writes the table as JSON
+func (t *QTable) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return helpers.WriteFile(path, data)
+}
+
+// LoadQTable reads a table saved by QTable.Save
+func LoadQTable(path string) (*QTable, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	table := &QTable{}
+	if err := json.Unmarshal(data, table); err != nil {
+		return nil, fmt.Errorf("Q-table %v could not be read: %v", path, err)
+	}
+	if table.Version != QTableVersion {
+		return nil, fmt.Errorf("Q-table %v has version %v, expected %v", path, table.Version, QTableVersion)
+	}
+	if len(table.Values) != table.states() || table.TicksPerDecision < 1 {
+		return nil, fmt.Errorf("Q-table %v does not match its bins", path)
+	}
+	for _, values := range table.Values {
+		if len(values) != QActions {
+			return nil, fmt.Errorf("Q-table %v does not have %v actions per state", path, QActions)
+		}
+	}
+	return table, nil
+}
+
+// qlearning flies greedily by a Q-table, holding every decision for TicksPerDecision ticks
+type qlearning struct {
+	table    *QTable
+	decision sim.Action
+	ticks    int
+}
+
+func loadQLearning(path string) (Manager, bool) {
+	if path == "" {
+		fmt.Println("QLearning input needs a Q-table, set one with --model")
+		return nil, true
+	}
+	table, err := LoadQTable(path)
+	if err != nil {
+		fmt.Println(err)
+		return nil, true
+	}
+	return &qlearning{table: table}, false
+}
+
+func (qlearning *qlearning) Act(obs sim.Observation) sim.Action {
+	if qlearning.ticks%qlearning.table.TicksPerDecision == 0 {
+		qlearning.decision = qlearning.table.Decide(obs, qlearning.table.Best(qlearning.table.State(obs)))
+	}
+	qlearning.ticks++
+	return qlearning.decision
+}
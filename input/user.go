@@ -5,31 +5,34 @@ import (
 	"github.com/renatobrittoaraujo/rl/sim"
 )
 
-const (
-	thrustChangePerSecond = 0.015
-)
-
-type user struct{}
+type user struct {
+	settings UserSettings
+	thrust   float32
+}
 
-func (user user) UpdateSim(rocket *sim.Rocket) {
+func (user *user) Act(obs sim.Observation) sim.Action {
 	if ebiten.IsKeyPressed(ebiten.KeyShift) {
-		thrust += thrustChangePerSecond
-		if thrust > 1 {
-			thrust = 1
+		user.thrust += user.settings.ThrustChangePerTick
+		if user.thrust > 1 {
+			user.thrust = 1
 		}
-		rocket.SetThrust(thrust)
 	}
 	if ebiten.IsKeyPressed(ebiten.KeyControl) {
-		thrust -= thrustChangePerSecond
-		if thrust < 0 {
-			thrust = 0
+		user.thrust -= user.settings.ThrustChangePerTick
+		if user.thrust < 0 {
+			user.thrust = 0
 		}
-		rocket.SetThrust(thrust)
+	}
+	action := sim.Action{
+		EngineOn: user.thrust > 0,
+		Thrust:   user.thrust,
+		Gimbal:   obs.Gimbal,
 	}
 	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
-		rocket.JetLeft()
+		action.RCS = sim.RCSLeft
 	}
 	if ebiten.IsKeyPressed(ebiten.KeyRight) {
-		rocket.JetRight()
+		action.RCS = sim.RCSRight
 	}
+	return action
 }
@@ -0,0 +1,168 @@
+package nn
+
+import "math"
+
+// Trace holds the input of every layer and the output of the last one, as computed by ForwardTrace
+type Trace struct {
+	values [][]float64
+}
+
+// Gradients has the shape of a network's weights and biases
+type Gradients struct {
+	Weights [][][]float64
+	Biases  [][]float64
+}
+
+// NewGradients returns zeroed gradients shaped as n
+func NewGradients(n *Network) *Gradients {
+	g := &Gradients{
+		Weights: make([][][]float64, len(n.Layers)),
+		Biases:  make([][]float64, len(n.Layers)),
+	}
+	for l, layer := range n.Layers {
+		g.Weights[l] = make([][]float64, len(layer.Weights))
+		for o, weights := range layer.Weights {
+			g.Weights[l][o] = make([]float64, len(weights))
+		}
+		g.Biases[l] = make([]float64, len(layer.Biases))
+	}
+	return g
+}
+
+// ForwardTrace is Forward that also returns what Backward needs
+func (n *Network) ForwardTrace(input []float64) ([]float64, *Trace) {
+	trace := &Trace{values: [][]float64{input}}
+	values := input
+	for l := range n.Layers {
+		values = n.forwardLayer(l, values)
+		trace.values = append(trace.values, values)
+	}
+	return values, trace
+}
+
+func (n *Network) forwardLayer(l int, values []float64) []float64 {
+	layer := &n.Layers[l]
+	next := make([]float64, len(layer.Biases))
+	for o, weights := range layer.Weights {
+		sum := layer.Biases[o]
+		for i, w := range weights {
+			sum += w * values[i]
+		}
+		next[o] = activate(layer.activation(o), sum)
+	}
+	return next
+}
+
+// Backward adds to grads the gradient of a loss with respect to every weight and bias,
+// given the gradient of that loss with respect to the outputs of the traced forward pass
+//
+// Returns the gradient with respect to the input
+func (n *Network) Backward(trace *Trace, outputGradient []float64, grads *Gradients) []float64 {
+	gradient := outputGradient
+	for l := len(n.Layers) - 1; l >= 0; l-- {
+		layer := &n.Layers[l]
+		input, output := trace.values[l], trace.values[l+1]
+		inputGradient := make([]float64, len(input))
+		for o, weights := range layer.Weights {
+			delta := gradient[o] * derivative(layer.activation(o), output[o])
+			if delta == 0 {
+				continue
+			}
+			grads.Biases[l][o] += delta
+			for i, w := range weights {
+				grads.Weights[l][o][i] += delta * input[i]
+				inputGradient[i] += delta * w
+			}
+		}
+		gradient = inputGradient
+	}
+	return gradient
+}
+
+// derivative returns the derivative of activation at the point where it outputs y
+func derivative(activation string, y float64) float64 {
+	switch activation {
+	case Tanh:
+		return 1 - y*y
+	case ReLU:
+		if y > 0 {
+			return 1
+		}
+		return 0
+	case Sigmoid:
+		return y * (1 - y)
+	}
+	return 1
+}
+
+// Scale multiplies every gradient by factor
+func (g *Gradients) Scale(factor float64) {
+	g.each(func(x *float64) { *x *= factor })
+}
+
+// Norm returns the euclidean norm of all gradients together
+func (g *Gradients) Norm() float64 {
+	sum := 0.0
+	g.each(func(x *float64) { sum += *x * *x })
+	return math.Sqrt(sum)
+}
+
+// Zero resets every gradient
+func (g *Gradients) Zero() {
+	g.each(func(x *float64) { *x = 0 })
+}
+
+func (g *Gradients) each(fn func(x *float64)) {
+	for l := range g.Weights {
+		for o := range g.Weights[l] {
+			for i := range g.Weights[l][o] {
+				fn(&g.Weights[l][o][i])
+			}
+		}
+		for o := range g.Biases[l] {
+			fn(&g.Biases[l][o])
+		}
+	}
+}
+
+// Adam is the Adam optimizer, its moments are exported so they can be checkpointed
+type Adam struct {
+	LearningRate float64
+	Beta1, Beta2 float64
+	Epsilon      float64
+	Steps        int
+	M, V         *Gradients // first and second moment estimates
+}
+
+// NewAdam returns an Adam optimizer for n with the usual betas
+func NewAdam(n *Network, learningRate float64) *Adam {
+	return &Adam{
+		LearningRate: learningRate,
+		Beta1:        0.9,
+		Beta2:        0.999,
+		Epsilon:      1e-8,
+		M:            NewGradients(n),
+		V:            NewGradients(n),
+	}
+}
+
+// Update moves the weights and biases of n against grads
+func (a *Adam) Update(n *Network, grads *Gradients) {
+	a.Steps++
+	correction1 := 1 - math.Pow(a.Beta1, float64(a.Steps))
+	correction2 := 1 - math.Pow(a.Beta2, float64(a.Steps))
+	update := func(param, grad, m, v *float64) {
+		g := *grad
+		*m = a.Beta1*(*m) + (1-a.Beta1)*g
+		*v = a.Beta2*(*v) + (1-a.Beta2)*g*g
+		*param -= a.LearningRate * (*m / correction1) / (math.Sqrt(*v/correction2) + a.Epsilon)
+	}
+	for l, layer := range n.Layers {
+		for o := range layer.Weights {
+			for i := range layer.Weights[o] {
+				update(&layer.Weights[o][i], &grads.Weights[l][o][i], &a.M.Weights[l][o][i], &a.V.Weights[l][o][i])
+			}
+			update(&layer.Biases[o], &grads.Biases[l][o], &a.M.Biases[l][o], &a.V.Biases[l][o])
+		}
+	}
+}
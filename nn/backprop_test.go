@@ -0,0 +1,61 @@
+package nn
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestBackwardGradientCheck compares Backward with central finite differences of the loss
+// sum(coefficients[k] * output[k]) for every weight, bias and input
+func TestBackwardGradientCheck(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	network := NewNetwork([]int{3, 5, 4, 5}, []string{Tanh, Sigmoid, Linear}, rng)
+	network.Layers[1].Activations = []string{ReLU, Tanh, Sigmoid, Linear}
+	network.Layers[2].Activations = []string{Linear, Tanh, Sigmoid, ReLU, Tanh}
+	for l := range network.Layers {
+		for o := range network.Layers[l].Biases {
+			network.Layers[l].Biases[o] = rng.NormFloat64() * 0.5
+		}
+	}
+	input := []float64{0.3, -0.7, 1.2}
+	coefficients := []float64{0.5, -1.5, 2, 1, -0.25}
+	loss := func() float64 {
+		total := 0.0
+		for k, value := range network.Forward(input) {
+			total += coefficients[k] * value
+		}
+		return total
+	}
+
+	_, trace := network.ForwardTrace(input)
+	grads := NewGradients(network)
+	inputGradient := network.Backward(trace, coefficients, grads)
+
+	const step = 1e-6
+	numeric := func(x *float64) float64 {
+		original := *x
+		*x = original + step
+		above := loss()
+		*x = original - step
+		below := loss()
+		*x = original
+		return (above - below) / (2 * step)
+	}
+	check := func(name string, analytic, numeric float64) {
+		if math.Abs(analytic-numeric) > 1e-6*math.Max(1, math.Abs(numeric)) {
+			t.Errorf("%v: backward %v, finite differences %v", name, analytic, numeric)
+		}
+	}
+	for l, layer := range network.Layers {
+		for o := range layer.Weights {
+			for i := range layer.Weights[o] {
+				check("weight", grads.Weights[l][o][i], numeric(&layer.Weights[o][i]))
+			}
+			check("bias", grads.Biases[l][o], numeric(&layer.Biases[o]))
+		}
+	}
+	for i := range input {
+		check("input", inputGradient[i], numeric(&input[i]))
+	}
+}
@@ -0,0 +1,250 @@
+package nn
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/renatobrittoaraujo/rl/helpers"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// ModelVersion is the version of the model format written by Save, bumped on any incompatible change
+const ModelVersion = 1
+
+// binaryMagic starts every model saved in the binary format
+const binaryMagic = "RLNN"
+
+// Model is a trained network along with everything needed to run it
+//
+// Inputs and Outputs name every value of the network's input and output
+// vectors in order, a model is only usable by a controller with the same layout.
+// Inputs are normalized as (value - InputMean) / InputStd before the forward pass
+type Model struct {
+	Version    int
+	Inputs     []string
+	Outputs    []string
+	InputMean  []float64
+	InputStd   []float64
+	SimVersion int         // sim.Version the model was trained on
+	Vehicle    sim.Vehicle // vehicle the model was trained on
+	Physics    sim.Physics // physics the model was trained on
+	Network    Network
+}
+
+// NewModel wraps network in a model trained on the current sim defaults, with no input normalization
+func NewModel(network Network, inputs, outputs []string) *Model {
+	model := &Model{
+		Version:    ModelVersion,
+		Inputs:     inputs,
+		Outputs:    outputs,
+		InputMean:  make([]float64, len(inputs)),
+		InputStd:   make([]float64, len(inputs)),
+		SimVersion: sim.Version,
+		Vehicle:    sim.DefaultVehicle,
+		Physics:    sim.DefaultPhysics,
+		Network:    network,
+	}
+	for i := range model.InputStd {
+		model.InputStd[i] = 1
+	}
+	return model
+}
+
+// Forward normalizes input and returns the output of the network
+func (m *Model) Forward(input []float64) []float64 {
+	normalized := make([]float64, len(input))
+	for i, value := range input {
+		normalized[i] = value - m.InputMean[i]
+		if m.InputStd[i] != 0 {
+			normalized[i] /= m.InputStd[i]
+		}
+	}
+	return m.Network.Forward(normalized)
+}
+
+// Validate returns an error if the model is malformed or was saved by an unknown format version
+func (m *Model) Validate() error {
+	if m.Version != ModelVersion {
+		return fmt.Errorf("model format version %v is not supported (expected %v)", m.Version, ModelVersion)
+	}
+	if err := m.Network.Validate(); err != nil {
+		return err
+	}
+	sizes := m.Network.Sizes()
+	if len(m.Inputs) != sizes[0] || len(m.Outputs) != sizes[len(sizes)-1] {
+		return fmt.Errorf("model names %v inputs and %v outputs but its network is %v", len(m.Inputs), len(m.Outputs), sizes)
+	}
+	if len(m.InputMean) != len(m.Inputs) || len(m.InputStd) != len(m.Inputs) {
+		return errors.New("model normalization stats do not match its inputs")
+	}
+	return nil
+}
+
+// Compatible returns an error if the model does not read inputs and write outputs in exactly the given layout
+func (m *Model) Compatible(inputs, outputs []string) error {
+	if !sameLayout(m.Inputs, inputs) {
+		return fmt.Errorf("model observes %v but the controller observes %v", m.Inputs, inputs)
+	}
+	if !sameLayout(m.Outputs, outputs) {
+		return fmt.Errorf("model acts with %v but the controller acts with %v", m.Outputs, outputs)
+	}
+	return nil
+}
+
+func sameLayout(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Save writes the model to path, as JSON if path ends in .json and in the compact binary format otherwise
+func (m *Model) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	var data []byte
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err = json.MarshalIndent(m, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = m.marshalBinary()
+	}
+	if err != nil {
+		return err
+	}
+	return helpers.WriteFile(path, data)
+}
+
+// Load reads a model saved by Save in either format and validates it
+func Load(path string) (*Model, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	model := &Model{}
+	if bytes.HasPrefix(data, []byte(binaryMagic)) {
+		err = model.unmarshalBinary(data)
+	} else {
+		err = json.Unmarshal(data, model)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("model %v could not be read: %v", path, err)
+	}
+	if err := model.Validate(); err != nil {
+		return nil, fmt.Errorf("model %v is invalid: %v", path, err)
+	}
+	return model, nil
+}
+
+// binaryHeader is the model without its weights and biases, which follow it as raw float64
+type binaryHeader struct {
+	Model
+	Sizes             []int
+	Activations       []string
+	OutputActivations [][]string `json:",omitempty"` // Layer.Activations of every layer, if any layer has them
+}
+
+// The binary format is binaryMagic, the length of a JSON binaryHeader as a
+// uint32, the header and then every layer's weights (row by row) followed by
+// its biases, all as little endian float64
+func (m *Model) marshalBinary() ([]byte, error) {
+	header := binaryHeader{Model: *m, Sizes: m.Network.Sizes()}
+	header.Network = Network{}
+	for _, layer := range m.Network.Layers {
+		header.Activations = append(header.Activations, layer.Activation)
+		if layer.Activations != nil {
+			header.OutputActivations = make([][]string, len(m.Network.Layers))
+		}
+	}
+	if header.OutputActivations != nil {
+		for l, layer := range m.Network.Layers {
+			header.OutputActivations[l] = layer.Activations
+		}
+	}
+	headerData, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString(binaryMagic)
+	binary.Write(&buffer, binary.LittleEndian, uint32(len(headerData)))
+	buffer.Write(headerData)
+	for _, layer := range m.Network.Layers {
+		for _, weights := range layer.Weights {
+			binary.Write(&buffer, binary.LittleEndian, weights)
+		}
+		binary.Write(&buffer, binary.LittleEndian, layer.Biases)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (m *Model) unmarshalBinary(data []byte) error {
+	reader := bufio.NewReader(bytes.NewReader(data[len(binaryMagic):]))
+	var headerLength uint32
+	if err := binary.Read(reader, binary.LittleEndian, &headerLength); err != nil {
+		return err
+	}
+	if int(headerLength) > len(data) {
+		return errors.New("header is longer than the file")
+	}
+	headerData := make([]byte, headerLength)
+	if _, err := io.ReadFull(reader, headerData); err != nil {
+		return err
+	}
+	var header binaryHeader
+	if err := json.Unmarshal(headerData, &header); err != nil {
+		return err
+	}
+	if len(header.Sizes) < 2 || len(header.Activations) != len(header.Sizes)-1 {
+		return errors.New("header does not describe any layer")
+	}
+	if header.OutputActivations != nil && len(header.OutputActivations) != len(header.Activations) {
+		return errors.New("header has output activations for some layers only")
+	}
+	*m = header.Model
+	m.Network.Layers = make([]Layer, len(header.Activations))
+	for l := range m.Network.Layers {
+		in, out := header.Sizes[l], header.Sizes[l+1]
+		if in <= 0 || out <= 0 || in*out > len(data) {
+			return fmt.Errorf("layer %v has impossible size %vx%v", l, in, out)
+		}
+		layer := Layer{
+			Activation: header.Activations[l],
+			Weights:    make([][]float64, out),
+			Biases:     make([]float64, out),
+		}
+		if header.OutputActivations != nil {
+			layer.Activations = header.OutputActivations[l]
+		}
+		for o := range layer.Weights {
+			layer.Weights[o] = make([]float64, in)
+			if err := binary.Read(reader, binary.LittleEndian, layer.Weights[o]); err != nil {
+				return err
+			}
+		}
+		if err := binary.Read(reader, binary.LittleEndian, layer.Biases); err != nil {
+			return err
+		}
+		m.Network.Layers[l] = layer
+	}
+	if _, err := reader.ReadByte(); err != io.EOF {
+		return errors.New("unexpected data after the last layer")
+	}
+	return nil
+}
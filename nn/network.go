@@ -0,0 +1,189 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Activation functions of a layer
+const (
+	Linear  = "linear"
+	Tanh    = "tanh"
+	ReLU    = "relu"
+	Sigmoid = "sigmoid"
+)
+
+// Layer is a fully connected layer, Weights[o][i] connects input i to output o
+//
+// Activations optionally sets the activation of each output, overriding Activation
+type Layer struct {
+	Activation  string
+	Activations []string `json:",omitempty"`
+	Weights     [][]float64
+	Biases      []float64
+}
+
+// Network is a multilayer perceptron, layers are applied in order
+type Network struct {
+	Layers []Layer
+}
+
+// NewNetwork creates a network with the given layer sizes (input size first) and
+// random weights, activations[i] is the activation of the layer after sizes[i]
+func NewNetwork(sizes []int, activations []string, rng *rand.Rand) *Network {
+	if len(activations) != len(sizes)-1 {
+		panic(fmt.Sprintf("Network with %v layer sizes needs %v activations, got %v", len(sizes), len(sizes)-1, len(activations)))
+	}
+	network := &Network{Layers: make([]Layer, len(sizes)-1)}
+	for l := range network.Layers {
+		in, out := sizes[l], sizes[l+1]
+		layer := Layer{
+			Activation: activations[l],
+			Weights:    make([][]float64, out),
+			Biases:     make([]float64, out),
+		}
+		// Xavier initialization keeps activations from saturating as networks get deeper
+		scale := math.Sqrt(2.0 / float64(in+out))
+		for o := range layer.Weights {
+			layer.Weights[o] = make([]float64, in)
+			for i := range layer.Weights[o] {
+				layer.Weights[o][i] = rng.NormFloat64() * scale
+			}
+		}
+		network.Layers[l] = layer
+	}
+	return network
+}
+
+// Clone returns a deep copy of the layer
+func (l Layer) Clone() Layer {
+	clone := l
+	if l.Activations != nil {
+		clone.Activations = append([]string(nil), l.Activations...)
+	}
+	clone.Weights = make([][]float64, len(l.Weights))
+	for o, weights := range l.Weights {
+		clone.Weights[o] = append([]float64(nil), weights...)
+	}
+	clone.Biases = append([]float64(nil), l.Biases...)
+	return clone
+}
+
+// Scale multiplies every weight and bias of the layer by factor
+func (l *Layer) Scale(factor float64) {
+	for _, weights := range l.Weights {
+		for i := range weights {
+			weights[i] *= factor
+		}
+	}
+	for o := range l.Biases {
+		l.Biases[o] *= factor
+	}
+}
+
+// Sizes returns the size of every layer, input size first
+func (n *Network) Sizes() []int {
+	if len(n.Layers) == 0 {
+		return nil
+	}
+	sizes := []int{len(n.Layers[0].Weights[0])}
+	for _, layer := range n.Layers {
+		sizes = append(sizes, len(layer.Biases))
+	}
+	return sizes
+}
+
+// Forward returns the output of the network for input
+func (n *Network) Forward(input []float64) []float64 {
+	values := input
+	for l := range n.Layers {
+		values = n.forwardLayer(l, values)
+	}
+	return values
+}
+
+// Validate returns an error if layers are not connected consistently or have unknown activations
+func (n *Network) Validate() error {
+	if len(n.Layers) == 0 {
+		return fmt.Errorf("network has no layers")
+	}
+	in := -1
+	for l, layer := range n.Layers {
+		if !validActivation(layer.Activation) {
+			return fmt.Errorf("layer %v has unknown activation %q", l, layer.Activation)
+		}
+		if layer.Activations != nil && len(layer.Activations) != len(layer.Biases) {
+			return fmt.Errorf("layer %v has %v activations for %v outputs", l, len(layer.Activations), len(layer.Biases))
+		}
+		for _, activation := range layer.Activations {
+			if !validActivation(activation) {
+				return fmt.Errorf("layer %v has unknown activation %q", l, activation)
+			}
+		}
+		if len(layer.Weights) == 0 || len(layer.Weights) != len(layer.Biases) {
+			return fmt.Errorf("layer %v has %v weight rows and %v biases", l, len(layer.Weights), len(layer.Biases))
+		}
+		for _, weights := range layer.Weights {
+			if in != -1 && len(weights) != in || len(weights) == 0 {
+				return fmt.Errorf("layer %v does not connect to the %v outputs of the previous layer", l, in)
+			}
+			in = len(weights)
+		}
+		in = len(layer.Biases)
+	}
+	if !n.finite() {
+		return fmt.Errorf("network has NaN or infinite weights")
+	}
+	return nil
+}
+
+// activation returns the activation of output o
+func (l *Layer) activation(o int) string {
+	if l.Activations != nil {
+		return l.Activations[o]
+	}
+	return l.Activation
+}
+
+func validActivation(activation string) bool {
+	switch activation {
+	case Linear, Tanh, ReLU, Sigmoid:
+		return true
+	}
+	return false
+}
+
+func activate(activation string, x float64) float64 {
+	switch activation {
+	case Tanh:
+		return math.Tanh(x)
+	case ReLU:
+		if x < 0 {
+			return 0
+		}
+		return x
+	case Sigmoid:
+		return 1 / (1 + math.Exp(-x))
+	}
+	return x
+}
+
+// finite returns false if any weight or bias is NaN or infinite, such models are a sign of a diverged trainer
+func (n *Network) finite() bool {
+	for _, layer := range n.Layers {
+		for _, weights := range layer.Weights {
+			for _, w := range weights {
+				if math.IsNaN(w) || math.IsInf(w, 0) {
+					return false
+				}
+			}
+		}
+		for _, b := range layer.Biases {
+			if math.IsNaN(b) || math.IsInf(b, 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
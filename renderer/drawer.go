@@ -14,6 +14,7 @@ import (
 const (
 	groundSlicePercentage = 0.15 // How much the dirt area occupy of the total space
 	grassSlicePercentage  = 0.03 // How much grass occupy of the dirt area
+	padHeight             = 6    // Pixels
 )
 
 var (
@@ -25,6 +26,7 @@ var (
 	groundImage, _     = ebiten.NewImage(int(width), int(height*groundSlicePercentage), ebiten.FilterDefault)
 	grassImage, _      = ebiten.NewImage(int(width), int(height*grassSlicePercentage), ebiten.FilterDefault)
 	featureImage, _    = ebiten.NewImage(int(width*0.6), 80, ebiten.FilterDefault)
+	padImage, _        = ebiten.NewImage(1, padHeight, ebiten.FilterDefault)
 )
 
 func init() {
@@ -33,6 +35,7 @@ func init() {
 	grassImage.Fill(color.RGBA{100, 240, 100, 255})      // Greenish
 	backgroundImage.Fill(color.RGBA{120, 120, 240, 255}) // Blueish
 	featureImage.Fill(color.White)
+	padImage.Fill(color.RGBA{90, 90, 90, 255}) // Concreteish
 }
 
 func drawSimulation(screen *ebiten.Image) {
@@ -55,6 +58,10 @@ func drawSimulation(screen *ebiten.Image) {
 	velocity /= sim.MaxLandingVelocity * 20
 	drawProgressBar(screen, velocity, screenWidth-30, 360, speedBar)
 
+	if ghost != nil {
+		drawGhost(screen, ghost, rocket)
+	}
+
 	drawRocket(screen, rocket)
 
 	drawParticles(screen, rocket)
@@ -68,16 +75,21 @@ func drawSimulation(screen *ebiten.Image) {
 	grassPos.Translate(0, groundPos)
 	screen.DrawImage(grassImage, &ebiten.DrawImageOptions{GeoM: grassPos})
 
+	if rocket.LandingPad != nil {
+		drawPad(screen, rocket.LandingPad, groundPos)
+	}
+
 	if rocket.IsAscending() {
 		text.Draw(screen, "ASCENTION", mplusBigFont, screenWidth/2-190, screenHeight-35, color.RGBA{255, 255, 255, 255})
 	} else if sim.DetectGroundCollision(rocket) > 0 && !rocket.IsAscending() {
-		success := sim.LandingScore(rocket) >= 0
+		success := sim.ClassifyLanding(rocket) == sim.OutcomeSuccess
 		msgColor := color.RGBA{255, 90, 90, 255}
 		if success {
 			msgColor = color.RGBA{70, 200, 70, 255}
 		}
 		drawImg(screen, featureImage, screenWidth/5-65, 65, 1)
 		text.Draw(screen, "Landing Score: "+fmt.Sprintf("%0.2f", sim.LandingScore(rocket)), mplusBigFont, screenWidth/2-420, 130, msgColor)
+		text.Draw(screen, "Outcome: "+sim.ClassifyLanding(rocket).String(), mplusBigFont, screenWidth/2-420, 200, msgColor)
 		text.Draw(screen, "Vertical Speed: "+fmt.Sprintf("%0.2f m/s", -rocket.SpeedVector.Y), mplusBigFont, screenWidth/2-420, 200+70, color.White)
 		text.Draw(screen, "Horizontal Speed: "+fmt.Sprintf("%0.2f m/s", rocket.SpeedVector.X), mplusBigFont, screenWidth/2-420, 270+70, color.White)
 		text.Draw(screen, "Angle: "+fmt.Sprintf("%0.2f°", rocket.Direction*180.0/math.Pi-90), mplusBigFont, screenWidth/2-420, 340+70, color.White)
@@ -87,6 +99,15 @@ func drawSimulation(screen *ebiten.Image) {
 	}
 }
 
+// drawPad draws the landing pad on the ground, positioned relative to the rocket that is always centered
+func drawPad(screen *ebiten.Image, pad *sim.Pad, groundPos float64) {
+	metersToPixels := float64(drawLenght) / float64(sim.RocketLenght)
+	pos := ebiten.GeoM{}
+	pos.Scale(float64(pad.MaxX-pad.MinX)*metersToPixels, 1)
+	pos.Translate(screenWidth/2+float64(pad.MinX-rocket.Position.X)*metersToPixels, groundPos-padHeight)
+	screen.DrawImage(padImage, &ebiten.DrawImageOptions{GeoM: pos})
+}
+
 func drawLoadingScreen(screen *ebiten.Image) {
 	screen.DrawImage(backgroundImage, &ebiten.DrawImageOptions{})
 
@@ -19,6 +19,8 @@ const (
 var (
 	rocketChannel    chan *sim.Rocket
 	rocket           *sim.Rocket
+	ghostChannel     chan *sim.Rocket
+	ghost            *sim.Rocket
 	lastRecordedTime time.Time
 	frames           int
 	lastFPS          int
@@ -42,9 +44,18 @@ func DrawSim(rc chan *sim.Rocket, fps int64) {
 	}
 }
 
+// DrawGhost makes the renderer draw rockets received from gc as a translucent
+// ghost next to the simulated rocket, must be called before DrawSim
+func DrawGhost(gc chan *sim.Rocket) {
+	ghostChannel = gc
+}
+
 // Draw to screen, required by ebiten interface
 // Drawn in order of priority in screen
 func (g *Game) Draw(screen *ebiten.Image) {
+	if ghostChannel != nil && len(ghostChannel) == cap(ghostChannel) {
+		ghost = <-ghostChannel
+	}
 	if rocketChannel != nil && len(rocketChannel) == cap(rocketChannel) {
 		rocket = <-rocketChannel
 		drawSimulation(screen)
@@ -17,6 +17,7 @@ const (
 	minRocketScale        = 0.3 // Percent
 	maxScreenHeightRocket = 0.9 // Percent
 	noScalingMaxHeight    = drawLenght * 1.5
+	ghostAlpha            = 0.35
 )
 
 var (
@@ -29,6 +30,17 @@ func init() {
 }
 
 func drawRocket(screen *ebiten.Image, rocket *sim.Rocket) {
+	rocketDrawPosition = drawRocketAt(screen, rocket, screenWidth/2, 1)
+}
+
+// drawGhost draws ghost translucent, positioned relative to the live rocket that is always centered
+func drawGhost(screen *ebiten.Image, ghost, rocket *sim.Rocket) {
+	offset := float64(ghost.Position.X-rocket.Position.X) * (float64(drawLenght) / float64(sim.RocketLenght))
+	drawRocketAt(screen, ghost, screenWidth/2+offset, ghostAlpha)
+}
+
+// drawRocketAt draws rocket at the horizontal screen position posX, returning where its center was drawn
+func drawRocketAt(screen *ebiten.Image, rocket *sim.Rocket, posX float64, alpha float64) sim.Point {
 	y := float64(rocket.Position.Y) * (float64(drawLenght) / float64(sim.RocketLenght))
 	scale := rocketScale(y)
 
@@ -37,13 +49,14 @@ func drawRocket(screen *ebiten.Image, rocket *sim.Rocket) {
 	pos.Translate(-drawLenght/20, -drawLenght/2) // Adjust image positioning to center of rocket
 	pos.Rotate(float64(rocket.Direction - math.Pi/2))
 
-	posX := float64(screenWidth / 2)
 	posY := float64(screenHeight)*(1.0-groundSlicePercentage) - rocketYPos(y)
 	pos.Translate(posX, posY)
 
-	rocketDrawPosition = sim.Point{X: float32(posX), Y: float32(posY)}
+	options := &ebiten.DrawImageOptions{GeoM: pos}
+	options.ColorM.Scale(1, 1, 1, alpha)
+	screen.DrawImage(rocketImage, options)
 
-	screen.DrawImage(rocketImage, &ebiten.DrawImageOptions{GeoM: pos})
+	return sim.Point{X: float32(posX), Y: float32(posY)}
 }
 
 func rocketScale(h float64) float64 {
@@ -42,5 +42,13 @@ func composePrint(rocket *sim.Rocket) (msg string) {
 		rocket.EngineStartsRemaining,
 		rocket.ThrustPercentage())
 
+	if ghost != nil {
+		msg += fmt.Sprintf(
+			"\n\n Ghost Altitude Delta: %+0.2f\n Ghost Speed Delta: %+0.2f m/s\n Ghost Fuel Delta: %+0.2f%%",
+			rocket.Position.Y-ghost.Position.Y,
+			rocket.Velocity()-ghost.Velocity(),
+			rocket.FuelPercentage()-ghost.FuelPercentage())
+	}
+
 	return
 }
@@ -0,0 +1,223 @@
+package runs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// listed is a run found in a runs directory
+type listed struct {
+	Dir string
+	Summary
+}
+
+// status is done, failed or unfinished, which is either still running or interrupted
+func (s Summary) status() string {
+	switch {
+	case s.Error != "":
+		return "failed"
+	case s.Finished.IsZero():
+		return "unfinished"
+	}
+	return "done"
+}
+
+func (s Summary) duration() string {
+	if s.Finished.IsZero() {
+		return "-"
+	}
+	return s.Finished.Sub(s.Started).Round(time.Second).String()
+}
+
+// shortCommit abbreviates a commit to 12 characters, keeping its -dirty suffix
+func shortCommit(commit string) string {
+	hash := strings.TrimSuffix(commit, "-dirty")
+	if len(hash) <= 12 {
+		return commit
+	}
+	return hash[:12] + strings.TrimPrefix(commit, hash)
+}
+
+func load(dir string) (listed, error) {
+	run := listed{Dir: dir}
+	data, err := ioutil.ReadFile(filepath.Join(dir, SummaryFile))
+	if err != nil {
+		return run, err
+	}
+	if err := json.Unmarshal(data, &run.Summary); err != nil {
+		return run, fmt.Errorf("summary of run %v could not be parsed: %v", dir, err)
+	}
+	return run, nil
+}
+
+// List prints every run inside root, oldest first, as a table or as JSON
+func List(root string, asJSON bool) error {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var found []listed
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if run, err := load(filepath.Join(root, entry.Name())); err == nil {
+			found = append(found, run)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Started.Before(found[j].Started) })
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(found)
+	}
+	if len(found) == 0 {
+		fmt.Println("No runs in", root)
+		return nil
+	}
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "Run\tCommand\tName\tStatus\tDuration\tGenerations\tScore\tSuccess\tCommit\t")
+	for _, run := range found {
+		fmt.Fprintf(table, "%v\t%v\t%v\t%v\t%v\t%v\t%0.3f\t%0.1f%%\t%v\t\n", filepath.Base(run.Dir), run.Command, run.Name,
+			run.status(), run.duration(), run.Generations, run.Score, run.SuccessRate*100, shortCommit(run.Commit))
+	}
+	return table.Flush()
+}
+
+// Compare prints the summaries, last metrics and config differences of runs side by side,
+// every run is either a run directory or the name of one inside root
+func Compare(root string, names []string) error {
+	var compared []listed
+	var configs []map[string]string
+	for _, name := range names {
+		dir := resolve(root, name)
+		run, err := load(dir)
+		if err != nil {
+			return fmt.Errorf("run %v could not be read: %v", name, err)
+		}
+		compared = append(compared, run)
+		config, err := flatConfig(filepath.Join(dir, ConfigFile))
+		if err != nil {
+			return err
+		}
+		configs = append(configs, config)
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	// row prints a line with the value of every run, by its index in compared
+	row := func(name string, value func(i int) string) {
+		fmt.Fprint(table, name, "\t")
+		for i := range compared {
+			fmt.Fprint(table, value(i), "\t")
+		}
+		fmt.Fprintln(table)
+	}
+	row("Run", func(i int) string { return filepath.Base(compared[i].Dir) })
+	row("Command", func(i int) string { return compared[i].Command })
+	row("Name", func(i int) string { return compared[i].Name })
+	row("Commit", func(i int) string { return shortCommit(compared[i].Commit) })
+	row("Status", func(i int) string { return compared[i].status() })
+	row("Duration", func(i int) string { return compared[i].duration() })
+	row("Generations", func(i int) string { return fmt.Sprint(compared[i].Generations) })
+	row("Score", func(i int) string {
+		run := compared[i]
+		if run.ScoreGeneration == 0 {
+			return fmt.Sprintf("%0.3f", run.Score)
+		}
+		return fmt.Sprintf("%0.3f (generation %v)", run.Score, run.ScoreGeneration)
+	})
+	row("Success", func(i int) string { return fmt.Sprintf("%0.1f%%", compared[i].SuccessRate*100) })
+
+	metrics := map[string]bool{}
+	for _, run := range compared {
+		for name := range run.Last {
+			metrics[name] = true
+		}
+	}
+	for _, name := range sortedKeys(metrics) {
+		row("Last "+name, func(i int) string {
+			if value, ok := compared[i].Last[name]; ok {
+				return fmt.Sprintf("%0.4g", value)
+			}
+			return "-"
+		})
+	}
+
+	keys := map[string]bool{}
+	for _, config := range configs {
+		for key := range config {
+			keys[key] = true
+		}
+	}
+	differences := 0
+	for _, key := range sortedKeys(keys) {
+		same := true
+		for _, config := range configs[1:] {
+			if config[key] != configs[0][key] {
+				same = false
+			}
+		}
+		if same {
+			continue
+		}
+		if differences == 0 {
+			fmt.Fprintln(table, "Config differences:\t")
+		}
+		differences++
+		row("  "+key, func(i int) string {
+			value, ok := configs[i][key]
+			if !ok {
+				return "-"
+			}
+			return value
+		})
+	}
+	if differences == 0 && len(compared) > 1 {
+		fmt.Fprintln(table, "Configs are identical\t")
+	}
+	return table.Flush()
+}
+
+// flatConfig returns every value of the config file at path by its dotted path, such as Trainer.PPO.Gamma
+func flatConfig(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("config %v could not be parsed: %v", path, err)
+	}
+	flat := map[string]string{}
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			encoded, _ := json.Marshal(value)
+			flat[prefix] = string(encoded)
+			return
+		}
+		for key, child := range object {
+			walk(strings.TrimPrefix(prefix+"."+key, "."), child)
+		}
+	}
+	walk("", config)
+	return flat, nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
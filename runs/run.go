@@ -0,0 +1,287 @@
+// Package runs keeps a directory per train or eval invocation, with everything needed to reproduce and compare it
+package runs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/renatobrittoaraujo/rl/helpers"
+)
+
+// Files and directories inside a run directory
+const (
+	// DirName is the directory inside the output directory that holds every run
+	DirName = "runs"
+	// ConfigFile is the effective config of the run
+	ConfigFile = "config.json"
+	// CommitFile is Commit, the git commit the binary was built from
+	CommitFile = "commit"
+	// SeedsFile lists the seeds of every generation (or of the evaluation), in the order they were flown
+	SeedsFile = "seeds.json"
+	// MetricsFile has a line of Metrics per generation
+	MetricsFile = "metrics.jsonl"
+	// CheckpointsDir holds the checkpoints of training
+	CheckpointsDir = "checkpoints"
+	// CheckpointFile is the latest checkpoint of the whole training state inside CheckpointsDir, runs resume from it
+	CheckpointFile = "checkpoint.json"
+	// SummaryFile is the Summary of the run, rewritten as it progresses
+	SummaryFile = "summary.json"
+)
+
+// Names of the metrics every trainer that measures them records
+const (
+	// MetricScore is the score a trainer improves: of the best individual for evolution, the mean score otherwise
+	MetricScore = "score"
+	// MetricSuccess is the rate of successful landings
+	MetricSuccess = "success"
+)
+
+// Commit is the git commit the binary was built from, with -dirty when it had uncommitted changes, stamped with
+//
+//	go build -ldflags "-X github.com/renatobrittoaraujo/rl/runs.Commit=$(git describe --always --dirty --abbrev=40)"
+//
+// Unstamped binaries fall back to the revision go build records from git, which go run leaves out
+var Commit string
+
+// Metrics are the values logged by a trainer after a generation
+type Metrics struct {
+	Generation int
+	Time       time.Time
+	Stage      string // curriculum stage, empty without a curriculum
+	Values     map[string]float64
+}
+
+// Summary describes a run at a glance
+type Summary struct {
+	Command         string   // train or eval
+	Name            string   // trainer, or the evaluated controller
+	Args            []string // flags of the command, a resumed run is continued with them
+	Commit          string
+	Started         time.Time
+	Finished        time.Time // zero while the run goes on, or if it was interrupted
+	Error           string    // why the run failed, empty if it did not
+	Generations     int
+	Score           float64 // highest MetricScore of training, median score of an evaluation
+	ScoreGeneration int     // generation of Score
+	SuccessRate     float64 // of an evaluation, or of the last generation that measured it
+	Model           string  // where the best controller was saved
+	Last            map[string]float64
+}
+
+// Run records a run to its directory, a nil Run records nothing
+type Run struct {
+	Dir     string
+	Summary Summary
+	seeds   [][]int
+	metrics *os.File
+}
+
+// Create creates the directory of a new run of command (train or eval) with flags args, named
+// after name inside root, recording the git commit the binary was built from
+func Create(root, command, name string, args []string) (*Run, error) {
+	started := time.Now()
+	dir := filepath.Join(root, name+"-"+started.Format("20060102-150405"))
+	for i := 2; exists(dir); i++ {
+		dir = filepath.Join(root, fmt.Sprintf("%v-%v-%v", name, started.Format("20060102-150405"), i))
+	}
+	if err := os.MkdirAll(filepath.Join(dir, CheckpointsDir), 0755); err != nil {
+		return nil, err
+	}
+	r := &Run{Dir: dir, Summary: Summary{Command: command, Name: name, Args: args, Commit: commit(), Started: started}}
+	if err := ioutil.WriteFile(r.Path(CommitFile), []byte(r.Summary.Commit+"\n"), 0644); err != nil {
+		return nil, err
+	}
+	fmt.Println("RUN:", dir)
+	return r, r.writeSummary()
+}
+
+// Open reopens the run at dir, or named dir inside root, to continue it
+func Open(root, dir string) (*Run, error) {
+	run, err := load(resolve(root, dir))
+	if err != nil {
+		return nil, fmt.Errorf("run %v could not be read: %v", dir, err)
+	}
+	r := &Run{Dir: run.Dir, Summary: run.Summary}
+	data, err := ioutil.ReadFile(r.Path(SeedsFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &r.seeds); err != nil {
+			return nil, fmt.Errorf("seeds of run %v could not be parsed: %v", r.Dir, err)
+		}
+	}
+	fmt.Println("RUN:", r.Dir)
+	return r, nil
+}
+
+// resolve returns name if it is a directory, otherwise the run named name inside root
+func resolve(root, name string) string {
+	if info, err := os.Stat(name); err == nil && info.IsDir() {
+		return name
+	}
+	return filepath.Join(root, name)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// commit returns Commit, or the revision go build read from git when it was not stamped, unknown if there is neither
+func commit() string {
+	if Commit != "" {
+		return Commit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	revision, dirty := "", ""
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			if setting.Value == "true" {
+				dirty = "-dirty"
+			}
+		}
+	}
+	if revision == "" {
+		return "unknown"
+	}
+	return revision + dirty
+}
+
+// Path returns the path of elem inside the run directory
+func (r *Run) Path(elem ...string) string {
+	return filepath.Join(append([]string{r.Dir}, elem...)...)
+}
+
+// Flew adds the seeds of a generation, or of an evaluation, to the seed list
+func (r *Run) Flew(seeds []int) error {
+	if r == nil {
+		return nil
+	}
+	r.seeds = append(r.seeds, append([]int(nil), seeds...))
+	return writeJSON(r.Path(SeedsFile), r.seeds)
+}
+
+// Generation appends m to the metrics and updates the summary with it
+func (r *Run) Generation(m Metrics) error {
+	if r == nil {
+		return nil
+	}
+	if r.metrics == nil {
+		file, err := os.OpenFile(r.Path(MetricsFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		r.metrics = file
+	}
+	m.Time = time.Now()
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if _, err := r.metrics.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	r.summarize(m)
+	return r.writeSummary()
+}
+
+// summarize updates the summary with the metrics of a generation
+func (r *Run) summarize(m Metrics) {
+	r.Summary.Generations = m.Generation
+	if score, ok := m.Values[MetricScore]; ok && (r.Summary.ScoreGeneration == 0 || score > r.Summary.Score) {
+		r.Summary.Score = score
+		r.Summary.ScoreGeneration = m.Generation
+	}
+	if success, ok := m.Values[MetricSuccess]; ok {
+		r.Summary.SuccessRate = success
+	}
+	r.Summary.Last = m.Values
+}
+
+// Rewind forgets every generation after generation, so a run continued from a checkpoint
+// of that generation records each of the following ones once
+func (r *Run) Rewind(generation int) error {
+	if r == nil {
+		return nil
+	}
+	if len(r.seeds) > generation {
+		r.seeds = r.seeds[:generation]
+	}
+	if err := writeJSON(r.Path(SeedsFile), r.seeds); err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(r.Path(MetricsFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	r.Summary.Finished = time.Time{}
+	r.Summary.Error = ""
+	r.Summary.Generations, r.Summary.Score, r.Summary.ScoreGeneration, r.Summary.SuccessRate, r.Summary.Last = 0, 0, 0, 0, nil
+	var kept []byte
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		var m Metrics
+		if json.Unmarshal([]byte(line), &m) != nil || m.Generation > generation {
+			continue
+		}
+		r.summarize(m)
+		kept = append(kept, line...)
+	}
+	if err := helpers.WriteFile(r.Path(MetricsFile), kept); err != nil {
+		return err
+	}
+	return r.writeSummary()
+}
+
+// Evaluated sets the median score and success rate of an evaluation
+func (r *Run) Evaluated(score, successRate float64) error {
+	if r == nil {
+		return nil
+	}
+	r.Summary.Score = score
+	r.Summary.SuccessRate = successRate
+	return r.writeSummary()
+}
+
+// Close marks the run finished, failed if err is not nil, returning err or the error of writing the summary
+func (r *Run) Close(err error) error {
+	if r == nil {
+		return err
+	}
+	if r.metrics != nil {
+		r.metrics.Close()
+	}
+	r.Summary.Finished = time.Now()
+	if err != nil {
+		r.Summary.Error = err.Error()
+	}
+	if writeErr := r.writeSummary(); err == nil {
+		err = writeErr
+	}
+	return err
+}
+
+func (r *Run) writeSummary() error {
+	return writeJSON(r.Path(SummaryFile), r.Summary)
+}
+
+// writeJSON writes v indented to path, aside first so readers never see it half written
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return helpers.WriteFile(path, append(data, '\n'))
+}
@@ -0,0 +1,86 @@
+package sim
+
+// RCS jets that an Action can fire
+const (
+	// RCSNone fires no jet
+	RCSNone = iota
+	// RCSLeft fires top left rcs jet (see Rocket.JetLeft)
+	RCSLeft
+	// RCSRight fires top right rcs jet (see Rocket.JetRight)
+	RCSRight
+)
+
+// Observation is a read-only snapshot of the rocket that is handed to a controller every tick
+//
+// Fuel, Thrust and Gimbal are percentages, all other units are the same as in Rocket
+type Observation struct {
+	Position              Point
+	Direction             float32
+	SpeedVector           Vector
+	AngularMomentum       float32
+	Fuel                  float32 // [0.0, 1.0]
+	Thrust                float32 // [0.0, 1.0]
+	Gimbal                float32 // [-1.0, 1.0]
+	EngineOn              bool
+	EngineStartsRemaining int
+}
+
+// Action is what a controller wants the rocket to do for the next tick
+//
+// Thrust is a percentage from [0.0, 1.0] and is ignored if EngineOn is false
+//
+// Gimbal is a percentage from [-1.0, 1.0] of Vehicle.MaxGimbalAngle, positive turns the rocket like JetRight
+type Action struct {
+	EngineOn bool
+	Thrust   float32
+	RCS      int
+	Gimbal   float32
+}
+
+// Observe returns a snapshot of the rocket for controllers
+func (r *Rocket) Observe() Observation {
+	return Observation{
+		Position:              r.Position,
+		Direction:             r.Direction,
+		SpeedVector:           r.SpeedVector,
+		AngularMomentum:       r.AngularMomentum,
+		Fuel:                  r.FuelPercentage(),
+		Thrust:                r.ThrustPercentage(),
+		Gimbal:                r.gimbal,
+		EngineOn:              r.thrust > 0,
+		EngineStartsRemaining: r.EngineStartsRemaining,
+	}
+}
+
+// Apply clamps action to the rocket's limits and applies it
+//
+// Returns the clamped action, applying it again on an identical rocket yields an identical rocket
+func (r *Rocket) Apply(a Action) Action {
+	a.Thrust = clamp(a.Thrust, 0, 1)
+	a.Gimbal = clamp(a.Gimbal, -1, 1)
+	if a.RCS != RCSLeft && a.RCS != RCSRight {
+		a.RCS = RCSNone
+	}
+	if !a.EngineOn {
+		a.Thrust = 0
+	}
+	r.SetThrust(a.Thrust)
+	switch a.RCS {
+	case RCSLeft:
+		r.JetLeft()
+	case RCSRight:
+		r.JetRight()
+	}
+	r.gimbal = a.Gimbal
+	return a
+}
+
+func clamp(v, min, max float32) float32 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,46 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+)
+
+// gustPeriod is how many seconds a gust takes to swing from one side and back
+const gustPeriod = 8
+
+// Disturbances push the rocket and blur what its controller observes after ascension
+//
+// Everything is derived from the episode's seed, so disturbed episodes are as repeatable as any other
+type Disturbances struct {
+	Wind        float32 // m/s^2 of steady sideways push, the seed picks whether it blows left or right
+	Gusts       float32 // m/s^2 of push swinging from side to side every gustPeriod seconds, on top of Wind
+	SensorNoise float32 // standard deviation of the noise added to observed position (m) and speed (m/s), a hundredth of it to direction (rad) and angular momentum
+}
+
+// push adds the wind of a seed to the rocket's speed for one tick
+func (d Disturbances) push(r *Rocket, seed int) {
+	if d.Wind == 0 && d.Gusts == 0 {
+		return
+	}
+	side := float32(1)
+	if seed%2 != 0 {
+		side = -1
+	}
+	phase := float64(seed%gustPeriod) / gustPeriod * 2 * math.Pi
+	gust := d.Gusts * float32(math.Sin(2*math.Pi*float64(r.SimulatedTime())/gustPeriod+phase))
+	r.SpeedVector.X += (side*d.Wind + gust) * physicsUpdateRate
+}
+
+// blur returns obs as seen through noisy sensors
+func (d Disturbances) blur(obs Observation, rng *rand.Rand) Observation {
+	noise := func(std float32) float32 {
+		return std * float32(rng.NormFloat64())
+	}
+	obs.Position.X += noise(d.SensorNoise)
+	obs.Position.Y += noise(d.SensorNoise)
+	obs.SpeedVector.X += noise(d.SensorNoise)
+	obs.SpeedVector.Y += noise(d.SensorNoise)
+	obs.Direction += noise(d.SensorNoise / 100)
+	obs.AngularMomentum += noise(d.SensorNoise / 100)
+	return obs
+}
@@ -0,0 +1,97 @@
+package sim
+
+import "math/rand"
+
+// Controller decides what the rocket does once ascension is over
+type Controller interface {
+	Act(Observation) Action
+}
+
+// Planner is a Controller that keeps state across a flight, Step shows it the live episode
+// right before every Act, to predict with clones of it or to tell flights apart. It must only
+// step clones of the episode
+type Planner interface {
+	Controller
+	Watch(e *Episode)
+}
+
+// Episode is a single flight, from liftoff until the rocket touches the ground or goes past its limits
+//
+// Given the same seed, limits, disturbances and actions, an episode always ends in the same state
+type Episode struct {
+	Rocket       *Rocket
+	Seed         int
+	Level        int
+	Limits       Limits
+	Disturbances Disturbances
+	Outcome      Outcome // only meaningful once the episode is over
+	noise        *rand.Rand
+}
+
+// NewEpisode creates a flight at level that ascends according to seed, within DefaultLimits
+func NewEpisode(seed int, level int) *Episode {
+	return NewEpisodeWith(seed, level, DefaultVehicle, DefaultPhysics)
+}
+
+// NewEpisodeWith is NewEpisode with the given vehicle and physics instead of the defaults
+func NewEpisodeWith(seed int, level int, vehicle Vehicle, physics Physics) *Episode {
+	return &Episode{
+		Rocket:  applyLevel(CreateRocketWith(vehicle, physics), level),
+		Seed:    seed,
+		Level:   level,
+		Limits:  DefaultLimits,
+		Outcome: OutcomeAborted,
+	}
+}
+
+// Step advances the episode one physics tick, controller only acts after ascension
+//
+// Returns the action that was applied (empty while ascending) and whether the flight is over
+func (e *Episode) Step(controller Controller) (action Action, done bool) {
+	r := e.Rocket
+	if r.IsAscending() {
+		r.Ascend(float32(e.Seed))
+	} else {
+		if planner, ok := controller.(Planner); ok {
+			planner.Watch(e)
+		}
+		obs := r.Observe()
+		if e.Disturbances.SensorNoise > 0 {
+			if e.noise == nil {
+				e.noise = rand.New(rand.NewSource(int64(e.Seed)))
+			}
+			obs = e.Disturbances.blur(obs, e.noise)
+		}
+		action = r.Apply(controller.Act(obs))
+		e.Disturbances.push(r, e.Seed)
+	}
+	r.Update()
+	if DetectGroundCollision(r) > 0 && !r.IsAscending() {
+		e.Outcome = ClassifyLanding(r)
+		return action, true
+	}
+	if outcome, ok := e.Limits.Check(r); ok {
+		e.Outcome = outcome
+		return action, true
+	}
+	return
+}
+
+// Clone returns a copy of e, stepping either one never changes the other
+//
+// The copy draws its own sensor noise, from the start of the episode's noise
+func (e *Episode) Clone() *Episode {
+	clone := *e
+	clone.Rocket = e.Rocket.Clone()
+	clone.noise = nil
+	return &clone
+}
+
+// Run steps the episode until it is over
+func (e *Episode) Run(controller Controller) {
+	for {
+		if _, done := e.Step(controller); done {
+			return
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package sim
+
+// Simulation levels, as described in the README
+const (
+	// LevelFree is the original rocket: limited fuel, 3 ignitions, lands anywhere
+	LevelFree = iota
+	// LevelUnlimitedIgnitions has limited fuel, unlimited ignitions and lands anywhere
+	LevelUnlimitedIgnitions
+	// LevelTwoIgnitions has limited fuel, 2 ignitions (one of them spent on liftoff) and lands anywhere
+	LevelTwoIgnitions
+	// LevelPad has limited fuel, 2 ignitions (one of them spent on liftoff) and must land on LevelPadArea
+	LevelPad
+)
+
+// UnlimitedIgnitions is the value of Rocket.EngineStartsRemaining when the engine can always be ignited
+const UnlimitedIgnitions = -1
+
+// LevelPadArea is the landing pad of LevelPad
+var LevelPadArea = Pad{MinX: -75, MaxX: 75}
+
+// ValidLevel returns true if level is one of the simulation levels
+func ValidLevel(level int) bool {
+	return level >= LevelFree && level <= LevelPad
+}
+
+// CreateRocketAtLevel creates a rocket with the restrictions of level
+func CreateRocketAtLevel(level int) *Rocket {
+	return applyLevel(CreateRocket(), level)
+}
+
+func applyLevel(r *Rocket, level int) *Rocket {
+	switch level {
+	case LevelUnlimitedIgnitions:
+		r.EngineStartsRemaining = UnlimitedIgnitions
+	case LevelTwoIgnitions:
+		r.EngineStartsRemaining = 2
+	case LevelPad:
+		r.EngineStartsRemaining = 2
+		pad := LevelPadArea
+		r.LandingPad = &pad
+	}
+	return r
+}
@@ -0,0 +1,52 @@
+package sim
+
+import "github.com/renatobrittoaraujo/rl/helpers"
+
+// Limits end flights that would otherwise never touch the ground, zero values disable a limit
+type Limits struct {
+	MaxFlightTime float32 // simulated seconds
+	MaxAltitude   float32 // meters
+	MaxDrift      float32 // meters away from liftoff in the X axis
+	Escape        bool    // ends escaping flights, requires MaxAltitude
+}
+
+// DefaultLimits are generous enough to never end a flight that could still land
+var DefaultLimits = Limits{
+	MaxFlightTime: 600,
+	MaxAltitude:   50000,
+	MaxDrift:      20000,
+	Escape:        true,
+}
+
+// Check returns the outcome of a flight that went past a limit, ok is false while flight is within limits
+func (l Limits) Check(r *Rocket) (outcome Outcome, ok bool) {
+	switch {
+	case l.MaxFlightTime > 0 && r.SimulatedTime() > l.MaxFlightTime:
+		return OutcomeTimedOut, true
+	case l.MaxAltitude > 0 && r.Position.Y > l.MaxAltitude:
+		return OutcomeMaxAltitude, true
+	case l.MaxDrift > 0 && (r.Position.X > l.MaxDrift || r.Position.X < -l.MaxDrift):
+		return OutcomeMaxDrift, true
+	case l.Escape && l.Escaping(r):
+		return OutcomeEscaped, true
+	}
+	return
+}
+
+// Escaping returns true if the rocket is accelerating upwards under power and
+// would go past MaxAltitude even if its engine was cut right now
+//
+// Climbing alone is not escaping: without fuel, with the engine off or with thrust
+// weaker than gravity, the rocket is bound to fall back down
+func (l Limits) Escaping(r *Rocket) bool {
+	if l.MaxAltitude <= 0 || r.SpeedVector.Y <= 0 || r.fuel <= 0 || r.thrust <= 0 {
+		return false
+	}
+	gravity := r.physics.Gravity
+	verticalAcceleration := r.thrust*helpers.Sinf32(r.Direction)/r.mass() - gravity
+	if verticalAcceleration <= 0 {
+		return false
+	}
+	apex := r.Position.Y + r.SpeedVector.Y*r.SpeedVector.Y/(2*gravity)
+	return apex > l.MaxAltitude
+}
@@ -0,0 +1,106 @@
+package sim
+
+import "math"
+
+// Outcome classifies how a flight ended
+type Outcome int
+
+const (
+	// OutcomeSuccess is a landing with a non negative LandingScore (on the pad, if there is one)
+	OutcomeSuccess Outcome = iota
+	// OutcomeHardLanding is a crash mostly due to vertical speed
+	OutcomeHardLanding
+	// OutcomeLateralSkid is a crash mostly due to horizontal speed
+	OutcomeLateralSkid
+	// OutcomeTippedOver is a crash mostly due to the rocket not being upright
+	OutcomeTippedOver
+	// OutcomeOutOfFuel is a crash due to speed where the rocket had no fuel left to brake
+	OutcomeOutOfFuel
+	// OutcomeOutOfIgnitions is a crash due to speed where the engine was off and could not be ignited again
+	OutcomeOutOfIgnitions
+	// OutcomeMissedPad is an otherwise successful landing outside the landing pad
+	OutcomeMissedPad
+	// OutcomeTimedOut is a flight that went past Limits.MaxFlightTime
+	OutcomeTimedOut
+	// OutcomeMaxAltitude is a flight that went past Limits.MaxAltitude
+	OutcomeMaxAltitude
+	// OutcomeMaxDrift is a flight that went past Limits.MaxDrift
+	OutcomeMaxDrift
+	// OutcomeEscaped is a flight that was escaping (see Limits.Escaping)
+	OutcomeEscaped
+	// OutcomeAborted is a flight that ended in the air for any other reason, such as a reset
+	OutcomeAborted
+)
+
+var outcomeStrings = [...]string{
+	"Success",
+	"Hard Landing",
+	"Lateral Skid",
+	"Tipped Over",
+	"Out Of Fuel",
+	"Out Of Ignitions",
+	"Missed Pad",
+	"Timed Out",
+	"Max Altitude",
+	"Max Drift",
+	"Escaped",
+	"Aborted",
+}
+
+func (o Outcome) String() string {
+	if o < 0 || int(o) >= len(outcomeStrings) {
+		return "Unknown"
+	}
+	return outcomeStrings[o]
+}
+
+// Landed returns true if the flight ended touching the ground, be it a success or a crash
+func (o Outcome) Landed() bool {
+	return o >= OutcomeSuccess && o < OutcomeTimedOut
+}
+
+// Pad is the area in the X axis where the rocket must land, every point of the rocket must be inside it
+type Pad struct {
+	MinX, MaxX float32
+}
+
+// Contains returns true if the whole rocket is within the pad
+func (p *Pad) Contains(r *Rocket) bool {
+	for _, point := range r.BoundingBox() {
+		if point.X < p.MinX || point.X > p.MaxX {
+			return false
+		}
+	}
+	return true
+}
+
+// ClassifyLanding returns the outcome of the flight as the rocket is now, flights
+// that have not touched the ground are aborted (Limits.Check tells why they should end)
+//
+// A crash is blamed on whatever went further past its limit, angle or speed. Speed crashes
+// are blamed on missing fuel or ignitions when the rocket could not have braked anymore
+func ClassifyLanding(r *Rocket) Outcome {
+	if DetectGroundCollision(r) == 0 {
+		return OutcomeAborted
+	}
+	if LandingScore(r) >= 0 {
+		if r.LandingPad != nil && !r.LandingPad.Contains(r) {
+			return OutcomeMissedPad
+		}
+		return OutcomeSuccess
+	}
+	angleFromUpright := math.Abs(math.Pi/2 - float64(r.Direction))
+	if angleFromUpright/MaxAngleDeviation >= float64(r.Velocity())/MaxLandingVelocity {
+		return OutcomeTippedOver
+	}
+	if r.fuel <= 0 {
+		return OutcomeOutOfFuel
+	}
+	if r.EngineStartsRemaining == 0 && r.thrust <= 0 {
+		return OutcomeOutOfIgnitions
+	}
+	if math.Abs(float64(r.SpeedVector.Y)) >= math.Abs(float64(r.SpeedVector.X)) {
+		return OutcomeHardLanding
+	}
+	return OutcomeLateralSkid
+}
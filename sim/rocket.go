@@ -8,22 +8,15 @@ import (
 	"github.com/renatobrittoaraujo/rl/helpers"
 )
 
-// Mass data from: https://sma.nasa.gov/LaunchVehicle/assets/spacex-falcon-9-data-sheet.pdf
 const (
-	// Data from Falcon 9 v1.1
-	RocketLenght    = 70      // meters
-	maxEngineThrust = 5885000 // newtons
-	dryMass         = 28000   // kilograms
-	wetMass         = 439000
+	// RocketLenght is the length of every rocket, as a Falcon 9 v1.1
+	RocketLenght = 70 // meters
+	// TickDuration is the simulated time of every physics tick, in seconds
+	TickDuration = physicsUpdateRate
 	// Constants related purely with simulation
-	ascentTime                          = 5 // seconds
-	maxEngineOnTime                     = 100
-	physicsUpdateRate                   = 1.0 / 60.0                            // per second
-	fuelComsumptionPerSecondAtMaxThrust = (wetMass - dryMass) / maxEngineOnTime // kg
-	rcsAngularMomentumChangePerTick     = 0.001                                 // kg m^2 s^-1
-	ascentionFrames                     = ascentTime * 60
-	// Actual physics constants
-	g = 9.8
+	ascentTime        = 5          // seconds
+	physicsUpdateRate = 1.0 / 60.0 // per second
+	ascentionFrames   = ascentTime * 60
 )
 
 // Rocket holds all relevant simulation data
@@ -35,6 +28,8 @@ const (
 // thrust given in newtons
 //
 // fuel given in kilograms
+//
+// LandingPad is nil if the rocket can land anywhere
 type Rocket struct {
 	Position              Point
 	Direction             float32
@@ -42,26 +37,57 @@ type Rocket struct {
 	AngularMomentum       float32
 	LiftoffTime           time.Time
 	EngineStartsRemaining int
+	LandingPad            *Pad
 	fuel                  float32
 	thrust                float32
+	gimbal                float32
 	frames                int
 	ascending             bool
+	vehicle               Vehicle
+	physics               Physics
 }
 
 // ================ ROCKET STRUCT HELPERS
 
-// CreateRocket creates and returns an instace of rocket
+// CreateRocket creates and returns an instace of rocket with DefaultVehicle and DefaultPhysics
 func CreateRocket() *Rocket {
+	return CreateRocketWith(DefaultVehicle, DefaultPhysics)
+}
+
+// CreateRocketWith creates and returns an instace of rocket with the given vehicle and physics
+func CreateRocketWith(vehicle Vehicle, physics Physics) *Rocket {
 	return &Rocket{
 		Position:              Point{X: 0, Y: RocketLenght / 2},
 		LiftoffTime:           time.Now(), // Simulation starts with liftoff, therefore this is appropriate
-		EngineStartsRemaining: 3,          // Falcon 9 v1.1 Merlin 1D's can ignite at least 3 times https://space.stackexchange.com/questions/13953/how-do-the-falcon-9-engines-re-ignite
-		fuel:                  wetMass - dryMass,
+		EngineStartsRemaining: vehicle.EngineStarts,
+		fuel:                  vehicle.WetMass - vehicle.DryMass,
 		Direction:             math.Pi / 2.0,
 		ascending:             true,
+		vehicle:               vehicle,
+		physics:               physics,
 	}
 }
 
+// Clone returns a copy of r, stepping either one never changes the other
+func (r *Rocket) Clone() *Rocket {
+	clone := *r
+	if r.LandingPad != nil {
+		pad := *r.LandingPad
+		clone.LandingPad = &pad
+	}
+	return &clone
+}
+
+// Vehicle returns the profile of the rocket
+func (r *Rocket) Vehicle() Vehicle {
+	return r.vehicle
+}
+
+// Physics returns the constants of the rocket's world
+func (r *Rocket) Physics() Physics {
+	return r.physics
+}
+
 // Update the rocket to it's next physics frame
 func (r *Rocket) Update() {
 	r.frames++
@@ -95,7 +121,8 @@ const (
 //
 // Any other seed generates pseudorandom, coherent and repeatable behaviour for any given input
 func (r *Rocket) Ascend(seed float32) {
-	duration := (helpers.Sinf32(cC*seed*seed)+1.0)*ascentionFrames/5 + ascentionFrames
+	lean, length := AscentProfile(seed)
+	duration := (length+1.0)*ascentionFrames/5 + ascentionFrames
 	if r.frames > int(duration) {
 		r.SetThrust(0)
 		r.ascending = false
@@ -109,7 +136,7 @@ func (r *Rocket) Ascend(seed float32) {
 	newThrust := (helpers.Sinf32(cA*seed*r.ThrustPercentage())+1.0)/20.0 + 0.9
 	r.SetThrust(newThrust)
 	// Target angle is varying from [67.5, 112.5]
-	targetAngle := math.Pi/2.0 + helpers.Cosf32(cB*seed)*math.Pi/8.0
+	targetAngle := math.Pi/2.0 + lean*math.Pi/8.0
 	if r.Direction > targetAngle {
 		r.JetLeft()
 	} else if r.Direction < targetAngle {
@@ -117,6 +144,13 @@ func (r *Rocket) Ascend(seed float32) {
 	}
 }
 
+// AscentProfile returns how seed shapes an ascension, both values vary from [-1.0, 1.0]
+//
+// lean tilts the ascension's target angle (positive leans to the left) and length stretches its duration
+func AscentProfile(seed float32) (lean, length float32) {
+	return helpers.Cosf32(cB * seed), helpers.Sinf32(cC * seed * seed)
+}
+
 // IsAscending returns true whether rocket is in ascension
 func (r *Rocket) IsAscending() bool {
 	return r.ascending
@@ -124,12 +158,12 @@ func (r *Rocket) IsAscending() bool {
 
 // JetLeft turns on top left rcs jet (in relation to rocket's top)
 func (r *Rocket) JetLeft() {
-	r.AngularMomentum -= rcsAngularMomentumChangePerTick
+	r.AngularMomentum -= r.vehicle.RCSImpulse
 }
 
 // JetRight turns on top right rcs jet (in relation to rocket's top)
 func (r *Rocket) JetRight() {
-	r.AngularMomentum += rcsAngularMomentumChangePerTick
+	r.AngularMomentum += r.vehicle.RCSImpulse
 }
 
 // SetThrust sets rocket thrust to a percentage from [0.0,1.0]
@@ -140,21 +174,21 @@ func (r *Rocket) SetThrust(percentage float32) (err bool) {
 	if (r.EngineStartsRemaining == 0 && r.thrust <= 0) || r.fuel <= 0 {
 		return false
 	}
-	if r.thrust == 0 && percentage > 0 {
+	if r.thrust == 0 && percentage > 0 && r.EngineStartsRemaining != UnlimitedIgnitions {
 		r.EngineStartsRemaining--
 	}
-	r.thrust = maxEngineThrust * percentage
+	r.thrust = r.vehicle.MaxThrust * percentage
 	return false
 }
 
 // FuelPercentage returns percentage from [0.0, 1.0] of fuel in rocket
 func (r *Rocket) FuelPercentage() float32 {
-	return r.fuel / (wetMass - dryMass)
+	return r.fuel / (r.vehicle.WetMass - r.vehicle.DryMass)
 }
 
 // ThrustPercentage returns percentage from [0.0, 1.0] of thrust
 func (r *Rocket) ThrustPercentage() float32 {
-	return r.thrust / maxEngineThrust
+	return r.thrust / r.vehicle.MaxThrust
 }
 
 // BoundingBox return the four points that define the rocket rectangle
@@ -193,6 +227,17 @@ func (r *Rocket) BoundingBox() [4]Point {
 	return points
 }
 
+// Ticks returns how many physics frames the rocket went through
+func (r *Rocket) Ticks() int {
+	return r.frames
+}
+
+// SimulatedTime returns seconds of simulated flight, independent of how fast the simulation runs
+func (r *Rocket) SimulatedTime() float32 {
+	return float32(r.frames) * physicsUpdateRate
+}
+
+// Velocity returns the module of the speed vector
 func (r *Rocket) Velocity() float32 {
 	return float32(math.Hypot(float64(r.SpeedVector.X), float64(r.SpeedVector.Y)))
 }
@@ -201,7 +246,7 @@ func (r *Rocket) Velocity() float32 {
 
 // mass of rocket in kilograms
 func (r *Rocket) mass() float32 {
-	return r.fuel + dryMass
+	return r.fuel + r.vehicle.DryMass
 }
 
 // tickFuel reduces fuel mass by current thurst amount
@@ -210,7 +255,7 @@ func (r *Rocket) tickFuel() {
 		r.fuel = 0
 		return
 	}
-	r.fuel -= r.ThrustPercentage() * fuelComsumptionPerSecondAtMaxThrust * physicsUpdateRate
+	r.fuel -= r.ThrustPercentage() * r.vehicle.fuelConsumption() * physicsUpdateRate
 	if r.fuel <= 0 {
 		r.fuel = 0
 		r.EngineStartsRemaining = 0
@@ -220,7 +265,7 @@ func (r *Rocket) tickFuel() {
 
 // Adds a little "friction" to rockets rotation, to simulate aerodinamics just a little
 func (r *Rocket) updateDirection() {
-	r.AngularMomentum *= 0.99
+	r.AngularMomentum *= r.physics.AngularDamping
 }
 
 // Updates angle rocket points based on it's angular momentum
@@ -248,12 +293,17 @@ func (r *Rocket) applyGravity() {
 		r.SpeedVector.Y = 0
 		return
 	}
-	r.SpeedVector.Y -= g * physicsUpdateRate
+	r.SpeedVector.Y -= r.physics.Gravity * physicsUpdateRate
 }
 
 // Adds to speed vector based on current engine's thrust
+//
+// A gimbaled engine pushes the base sideways, so thrust is deflected
+// away from the rocket's direction and the rocket turns
 func (r *Rocket) addThrust() {
+	deflection := r.gimbal * r.vehicle.MaxGimbalAngle
+	r.AngularMomentum += r.ThrustPercentage() * r.gimbal * r.vehicle.GimbalImpulse
 	module := r.thrust * physicsUpdateRate / r.mass()
-	r.SpeedVector.X += helpers.Cosf32(r.Direction) * module
-	r.SpeedVector.Y += helpers.Sinf32(r.Direction) * module
+	r.SpeedVector.X += helpers.Cosf32(r.Direction-deflection) * module
+	r.SpeedVector.Y += helpers.Sinf32(r.Direction-deflection) * module
 }
@@ -0,0 +1,224 @@
+package sim
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// Scorer rates a landing, a non negative score is a successful landing (see ClassifyLanding)
+type Scorer interface {
+	Name() string
+	// Version is bumped whenever the scorer's formula changes, scores only compare between equal names and versions
+	Version() int
+	// Weights is a hash of the weights scaling the scorer's terms, scores only compare between equal weights too
+	Weights() string
+	Score(r *Rocket) float32
+}
+
+// Names of every scorer
+const (
+	// ScorerLogistic rates speed and angle from upright with logistic functions, the original score
+	ScorerLogistic = "logistic"
+	// ScorerFuel is ScorerLogistic plus a bonus for the fuel left
+	ScorerFuel = "fuel"
+	// ScorerPad is ScorerLogistic minus a penalty for the distance to the center of the pad
+	ScorerPad = "pad"
+	// ScorerSplitSpeed is ScorerLogistic with separate vertical and horizontal speed limits
+	ScorerSplitSpeed = "split-speed"
+	// ScorerAngularRate is ScorerLogistic that also limits how fast the rocket rotates at touchdown
+	ScorerAngularRate = "angular-rate"
+	// ScorerFull has the split speed, angle and angular rate limits and the fuel and pad terms
+	ScorerFull = "full"
+)
+
+// Scorers are the names accepted by ScoreWeights.Scorer
+var Scorers = []string{ScorerLogistic, ScorerFuel, ScorerPad, ScorerSplitSpeed, ScorerAngularRate, ScorerFull}
+
+// ScoreWeights choose the scorer of LandingScore and scale each of its terms
+type ScoreWeights struct {
+	Scorer             string  // one of Scorers, empty is ScorerLogistic
+	Speed              float64 // weight of the speed term, or of the worst of the vertical and horizontal ones
+	Angle              float64
+	AngularRate        float64
+	Fuel               float64 // points for landing with a full tank
+	PadDistance        float64 // points lost at the edge of the pad (LevelPadArea if the level has none), growing with the square of the distance to its center
+	MaxVerticalSpeed   float64 // m/s, limit of the vertical speed term
+	MaxHorizontalSpeed float64 // m/s, limit of the horizontal speed term
+	MaxAngularRate     float64 // rad/s, limit of the angular rate term
+}
+
+// DefaultScoreWeights are the weights used by LandingScore
+var DefaultScoreWeights = ScoreWeights{
+	Scorer:             ScorerLogistic,
+	Speed:              1,
+	Angle:              1,
+	AngularRate:        1,
+	Fuel:               2,
+	PadDistance:        2,
+	MaxVerticalSpeed:   15,
+	MaxHorizontalSpeed: 10,
+	MaxAngularRate:     1,
+}
+
+// DefaultScorer is the scorer used by LandingScore, set it with NewScorer
+var DefaultScorer Scorer = logisticScorer(DefaultScoreWeights)
+
+// LandingScore is a score of a particular landing, rated by DefaultScorer
+//
+// ret >= 0 means a successful landing and < 0 unsuccessful landing
+func LandingScore(r *Rocket) float32 {
+	return DefaultScorer.Score(r)
+}
+
+// UnlandedScore is the score of flights that end without touching the ground, below any LandingScore of valid
+// score weights (see ScoreWeights.MinScore)
+const UnlandedScore = -10
+
+// FlightScore is the score of a flight that ended with outcome, its LandingScore or UnlandedScore if it never landed
+func FlightScore(r *Rocket, outcome Outcome) float32 {
+	if !outcome.Landed() {
+		return UnlandedScore
+	}
+	return LandingScore(r)
+}
+
+// NewScorer returns the scorer named by weights.Scorer with its terms scaled by weights
+func NewScorer(weights ScoreWeights) (Scorer, error) {
+	switch weights.Scorer {
+	case "", ScorerLogistic:
+		return logisticScorer(weights), nil
+	case ScorerFuel:
+		return termScorer{name: ScorerFuel, version: 1, weights: weights, limits: []scoreTerm{speedTerm, angleTerm}, bonuses: []scoreTerm{fuelTerm}}, nil
+	case ScorerPad:
+		return termScorer{name: ScorerPad, version: 1, weights: weights, limits: []scoreTerm{speedTerm, angleTerm}, bonuses: []scoreTerm{padTerm}}, nil
+	case ScorerSplitSpeed:
+		return termScorer{name: ScorerSplitSpeed, version: 1, weights: weights, limits: []scoreTerm{splitSpeedTerm, angleTerm}}, nil
+	case ScorerAngularRate:
+		return termScorer{name: ScorerAngularRate, version: 1, weights: weights, limits: []scoreTerm{speedTerm, attitudeTerm}}, nil
+	case ScorerFull:
+		return termScorer{name: ScorerFull, version: 1, weights: weights, limits: []scoreTerm{splitSpeedTerm, attitudeTerm}, bonuses: []scoreTerm{fuelTerm, padTerm}}, nil
+	}
+	return nil, fmt.Errorf("unknown scorer %q", weights.Scorer)
+}
+
+// MinScore is the lowest score the scorer chosen by w can give a landing, every limit term at its worst
+func (w ScoreWeights) MinScore() float64 {
+	attitude := math.Abs(w.Angle)
+	if w.Scorer == ScorerAngularRate || w.Scorer == ScorerFull {
+		attitude = math.Max(attitude, math.Abs(w.AngularRate))
+	}
+	return 1 - 5*(math.Abs(w.Speed)+attitude)
+}
+
+// used returns the weights read by the scorer chosen by w, every other weight and Scorer left zero
+func (w ScoreWeights) used() ScoreWeights {
+	used := ScoreWeights{Speed: w.Speed, Angle: w.Angle}
+	switch w.Scorer {
+	case ScorerFuel:
+		used.Fuel = w.Fuel
+	case ScorerPad:
+		used.PadDistance = w.PadDistance
+	case ScorerSplitSpeed:
+		used.MaxVerticalSpeed, used.MaxHorizontalSpeed = w.MaxVerticalSpeed, w.MaxHorizontalSpeed
+	case ScorerAngularRate:
+		used.AngularRate, used.MaxAngularRate = w.AngularRate, w.MaxAngularRate
+	case ScorerFull:
+		used = w
+		used.Scorer = ""
+	}
+	return used
+}
+
+func logisticScorer(weights ScoreWeights) Scorer {
+	return termScorer{name: ScorerLogistic, version: 1, weights: weights, limits: []scoreTerm{speedTerm, angleTerm}}
+}
+
+// scoreTerm is the contribution of a single aspect of a landing to its score
+type scoreTerm func(r *Rocket, w ScoreWeights) float64
+
+// termScorer adds up limit terms, which decide whether a landing is successful, then bonus terms
+//
+// Bonuses only apply to successful landings and never take them below 0, so they rank successes without changing which landings succeed
+type termScorer struct {
+	name    string
+	version int
+	weights ScoreWeights
+	limits  []scoreTerm
+	bonuses []scoreTerm
+}
+
+func (s termScorer) Name() string {
+	return s.name
+}
+
+func (s termScorer) Version() int {
+	return s.version
+}
+
+func (s termScorer) Weights() string {
+	hash := fnv.New32a()
+	fmt.Fprintf(hash, "%+v", s.weights.used())
+	return fmt.Sprintf("%08x", hash.Sum32())
+}
+
+func (s termScorer) Score(r *Rocket) float32 {
+	score := 1.0
+	for _, term := range s.limits {
+		score += term(r, s.weights)
+	}
+	if score >= 0 && len(s.bonuses) > 0 {
+		for _, term := range s.bonuses {
+			score += term(r, s.weights)
+		}
+		score = math.Max(score, 0)
+	}
+	return float32(score)
+}
+
+// logistic is 5 well below limit, 0 at 80% of it and -5 well above it
+func logistic(x, limit float64) float64 {
+	return 10.0/(1+math.Exp(12/limit*(x-limit*0.8))) - 5.0
+}
+
+// Logistical function, below 20 speed it gives positive and negative for everything else
+// These numbers are magical and based on MaxLandingVelocity = 20
+// If you wish to change them, use these coeficients below here:
+// https://www.desmos.com/calculator
+func speedTerm(r *Rocket, w ScoreWeights) float64 {
+	return w.Speed * (10.0/(1+math.Exp(0.6*(float64(r.Velocity())-MaxLandingVelocity*0.8))) - 5.0)
+}
+
+func angleTerm(r *Rocket, w ScoreWeights) float64 {
+	angleFromUpright := math.Abs(math.Pi/2 - float64(r.Direction))
+	return w.Angle * (10.0/(1+math.Exp(0.9*(angleFromUpright-MaxAngleDeviation*0.8))) - 5.0)
+}
+
+// splitSpeedTerm rates the worse of vertical and horizontal speed against their own limits
+func splitSpeedTerm(r *Rocket, w ScoreWeights) float64 {
+	vertical := logistic(math.Abs(float64(r.SpeedVector.Y)), w.MaxVerticalSpeed)
+	horizontal := logistic(math.Abs(float64(r.SpeedVector.X)), w.MaxHorizontalSpeed)
+	return w.Speed * math.Min(vertical, horizontal)
+}
+
+// attitudeTerm rates the worse of the angle from upright and how fast the rocket rotates
+//
+// Limits are combined by their worst so every scorer keeps the range of ScorerLogistic
+func attitudeTerm(r *Rocket, w ScoreWeights) float64 {
+	rate := w.AngularRate * logistic(math.Abs(float64(r.AngularMomentum)), w.MaxAngularRate)
+	return math.Min(angleTerm(r, w), rate)
+}
+
+func fuelTerm(r *Rocket, w ScoreWeights) float64 {
+	return w.Fuel * float64(r.FuelPercentage())
+}
+
+func padTerm(r *Rocket, w ScoreWeights) float64 {
+	pad := LevelPadArea
+	if r.LandingPad != nil {
+		pad = *r.LandingPad
+	}
+	center, half := float64(pad.MinX+pad.MaxX)/2, float64(pad.MaxX-pad.MinX)/2
+	distance := (float64(r.Position.X) - center) / half
+	return -w.PadDistance * distance * distance
+}
@@ -0,0 +1,53 @@
+package sim
+
+// Version of the simulated dynamics, bumped whenever a change makes a trained
+// controller fly differently than it did when it was trained
+const Version = 1
+
+// Vehicle is the profile of the rocket being flown
+type Vehicle struct {
+	MaxThrust       float32 // newtons
+	DryMass         float32 // kilograms
+	WetMass         float32 // kilograms
+	MaxEngineOnTime float32 // seconds the engine burns at max thrust until the fuel ends
+	EngineStarts    int     // ignitions available at liftoff
+	RCSImpulse      float32 // angular momentum change per tick of a rcs jet (kg m^2 s^-1)
+	GimbalImpulse   float32 // angular momentum change per tick at max thrust and max gimbal (kg m^2 s^-1)
+	MaxGimbalAngle  float32 // radians
+}
+
+// Physics holds the constants of the simulated world
+type Physics struct {
+	Gravity        float32 // m/s^2
+	AngularDamping float32 // fraction of angular momentum kept every tick, a little "friction" to simulate aerodynamics
+}
+
+// Mass data from: https://sma.nasa.gov/LaunchVehicle/assets/spacex-falcon-9-data-sheet.pdf
+var (
+	// Falcon9 is the Falcon 9 v1.1 first stage
+	Falcon9 = Vehicle{
+		MaxThrust:       5885000,
+		DryMass:         28000,
+		WetMass:         439000,
+		MaxEngineOnTime: 100,
+		EngineStarts:    3, // Falcon 9 v1.1 Merlin 1D's can ignite at least 3 times https://space.stackexchange.com/questions/13953/how-do-the-falcon-9-engines-re-ignite
+		RCSImpulse:      0.001,
+		GimbalImpulse:   0.003,
+		MaxGimbalAngle:  0.087, // Merlin 1D gimbals about 5 degrees
+	}
+	// Earth is the world the simulation was made for
+	Earth = Physics{
+		Gravity:        9.8,
+		AngularDamping: 0.99,
+	}
+
+	// DefaultVehicle is the vehicle of rockets created by CreateRocket
+	DefaultVehicle = Falcon9
+	// DefaultPhysics is the world of rockets created by CreateRocket
+	DefaultPhysics = Earth
+)
+
+// fuelConsumption returns kilograms of fuel burnt per second at max thrust
+func (v Vehicle) fuelConsumption() float32 {
+	return (v.WetMass - v.DryMass) / v.MaxEngineOnTime
+}
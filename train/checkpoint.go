@@ -0,0 +1,94 @@
+package train
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/renatobrittoaraujo/rl/helpers"
+	"github.com/renatobrittoaraujo/rl/runs"
+)
+
+// checkpoint is the state of a training run between two generations, enough to continue it exactly
+// as if it had never stopped, as long as Workers does not change
+type checkpoint struct {
+	Trainer    string
+	Generation int             // generations done
+	Seed       int64           // seed of the trainer's random number generator
+	Draws      uint64          // numbers drawn from it so far
+	Stage      int             // curriculum stage
+	Results    []bool          // curriculum results of the stage so far
+	State      json.RawMessage // of the trainer: population, networks and optimizers, Q-table...
+}
+
+// checkpointPath is where checkpoints are written, in the run's checkpoints directory or next to opts.ModelPath without a run
+func checkpointPath(opts Options) string {
+	if opts.Run != nil {
+		return opts.Run.Path(runs.CheckpointsDir, runs.CheckpointFile)
+	}
+	return opts.ModelPath + ".checkpoint.json"
+}
+
+// checkpointDue returns true if a checkpoint is to be written after generation
+func checkpointDue(opts Options, generation int) bool {
+	return opts.CheckpointEvery > 0 && generation%opts.CheckpointEvery == 0
+}
+
+// saveCheckpoint writes state, the trainer's own, with the generator drawing from rng, the curriculum
+// and how many generations are done to checkpointPath
+func saveCheckpoint(opts Options, generation int, rng *source, state interface{}) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	cp := checkpoint{Trainer: opts.Trainer, Generation: generation, Seed: rng.seed, Draws: rng.draws, State: data}
+	if c := opts.curriculum; c != nil {
+		cp.Stage, cp.Results = c.stage, c.results
+	}
+	if data, err = json.Marshal(cp); err != nil {
+		return err
+	}
+	path := checkpointPath(opts)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := helpers.WriteFile(path, data); err != nil {
+		return err
+	}
+	fmt.Printf("CHECKPOINT SAVED: %v (generation %v)\n", path, generation)
+	return nil
+}
+
+// loadCheckpoint reads the checkpoint at opts.Resume into state, restores the curriculum and rewinds
+// opts.Run to it, returning how many generations were done and the generator to go on drawing from
+func loadCheckpoint(opts Options, state interface{}) (generation int, rng *rand.Rand, src *source, err error) {
+	data, err := ioutil.ReadFile(opts.Resume)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return 0, nil, nil, fmt.Errorf("checkpoint %v could not be read: %v", opts.Resume, err)
+	}
+	if cp.Trainer != opts.Trainer {
+		return 0, nil, nil, fmt.Errorf("checkpoint %v was written by trainer %v, not %v", opts.Resume, cp.Trainer, opts.Trainer)
+	}
+	if err := json.Unmarshal(cp.State, state); err != nil {
+		return 0, nil, nil, fmt.Errorf("checkpoint %v could not be read: %v", opts.Resume, err)
+	}
+	if c := opts.curriculum; c != nil {
+		if cp.Stage >= len(c.settings.Stages) {
+			return 0, nil, nil, fmt.Errorf("checkpoint %v is at curriculum stage %v, there are only %v", opts.Resume, cp.Stage+1, len(c.settings.Stages))
+		}
+		c.stage, c.results = cp.Stage, cp.Results
+	}
+	if err := opts.Run.Rewind(cp.Generation); err != nil {
+		return 0, nil, nil, err
+	}
+	rng, src = newRand(cp.Seed, cp.Draws)
+	fmt.Printf("RESUMED FROM: %v (generation %v)\n", opts.Resume, cp.Generation)
+	return cp.Generation, rng, src, nil
+}
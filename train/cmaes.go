@@ -0,0 +1,306 @@
+package train
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/renatobrittoaraujo/rl/input"
+	"github.com/renatobrittoaraujo/rl/runs"
+)
+
+// CMAESSettings holds the hyperparameters of CMA-ES (Covariance Matrix Adaptation Evolution Strategy)
+type CMAESSettings struct {
+	Sigma            float64 // initial step size, in units of the controller's parameters
+	PopulationSize   int     // candidates per generation of the first run, at least 2, 0 picks 4 + 3 ln(parameters)
+	Restarts         int     // IPOP restarts after a run converges or stalls, each doubling the population
+	TolX             float64 // a run has converged when its steps are all smaller than this
+	StallGenerations int     // a run has stalled when its best fitness did not improve in this many generations
+}
+
+// DefaultCMAESSettings are the hyperparameters suggested by Hansen's CMA-ES tutorial
+var DefaultCMAESSettings = CMAESSettings{
+	Sigma:            0.3,
+	Restarts:         4,
+	TolX:             1e-6,
+	StallGenerations: 30,
+}
+
+// cmaesRun is the state of a single CMA-ES run, between restarts
+type cmaesRun struct {
+	Lambda          int // candidates per generation
+	Mean            []float64
+	Sigma           float64
+	C               [][]float64 // covariance matrix
+	B               [][]float64 // eigenvectors of C, as columns
+	D               []float64   // square roots of the eigenvalues of C
+	PC, PS          []float64   // evolution paths of C and Sigma
+	Generation      int
+	EigenGeneration int // generation B and D were last computed
+	Best            float64
+	BestGeneration  int
+}
+
+// cmaesCheckpoint is the state of a CMA-ES optimization between generations (see checkpoint)
+type cmaesCheckpoint struct {
+	Generation     int // generations done over every run
+	Restart        int
+	Run            cmaesRun
+	Initial        []float64 // parameters the first run started from
+	BestParameters []float64 // parameters of the saved controller
+}
+
+type cmaes struct {
+	opts     Options
+	settings CMAESSettings
+	tunable  input.Tunable
+	state    cmaesCheckpoint
+	source   *source
+	// Constants of the current run, derived from its population size
+	mu                                  int
+	weights                             []float64
+	mueff, cc, cs, c1, cmu, damps, chiN float64
+}
+
+func runCMAES(opts Options) error {
+	if opts.Tunable == nil {
+		return fmt.Errorf("CMA-ES needs a controller with parameters to tune")
+	}
+	if opts.SeedsPerEvaluation < 1 {
+		return fmt.Errorf("CMA-ES needs at least 1 seed per evaluation, got %v", opts.SeedsPerEvaluation)
+	}
+	c := &cmaes{opts: opts, settings: opts.CMAES, tunable: opts.Tunable}
+	var rng *rand.Rand
+	if opts.Resume != "" {
+		var err error
+		if _, rng, c.source, err = loadCheckpoint(opts, &c.state); err != nil {
+			return err
+		}
+		if len(c.state.Initial) != len(c.tunable.Parameters()) {
+			return fmt.Errorf("checkpoint %v tunes %v parameters but the controller has %v", opts.Resume, len(c.state.Initial), len(c.tunable.Parameters()))
+		}
+	} else {
+		rng, c.source = newRand(opts.Seed, 0)
+		initial := opts.Tunable.Parameters()
+		c.state = cmaesCheckpoint{Initial: initial}
+		lambda := c.settings.PopulationSize
+		if lambda <= 0 {
+			lambda = 4 + int(3*math.Log(float64(len(initial))))
+		}
+		c.startRun(initial, lambda)
+	}
+	c.constants()
+
+	for c.state.Generation < opts.Generations {
+		c.state.Generation++
+		run := &c.state.Run
+		run.Generation++
+		c.updateEigen()
+
+		seeds, err := opts.curriculum.seeds(rng, opts.SeedsPerEvaluation)
+		if err != nil {
+			return err
+		}
+		candidates := make([][]float64, run.Lambda)
+		steps := make([][]float64, run.Lambda)
+		for k := range candidates {
+			z := make([]float64, len(run.Mean))
+			for i := range z {
+				z[i] = rng.NormFloat64() * run.D[i]
+			}
+			steps[k] = multiply(run.B, z)
+			candidates[k] = make([]float64, len(run.Mean))
+			for i := range candidates[k] {
+				candidates[k][i] = run.Mean[i] + run.Sigma*steps[k][i]
+			}
+		}
+		fitness := make([]float64, run.Lambda)
+		parallel(run.Lambda, opts.Workers, func(k int) {
+			fitness[k] = meanScore(c.tunable.WithParameters(candidates[k]), seeds, opts)
+		})
+		order := make([]int, run.Lambda)
+		for k := range order {
+			order[k] = k
+		}
+		sort.SliceStable(order, func(i, j int) bool { return fitness[order[i]] > fitness[order[j]] })
+		c.update(candidates, steps, order)
+
+		best := order[0]
+		successes := flightSuccesses(c.tunable.WithParameters(candidates[best]), seeds, opts)
+		if opts.curriculum != nil {
+			opts.curriculum.record(successes)
+		}
+		fmt.Printf("GENERATION %v: run %v population %v best %.3f median %.3f sigma %.4g%v\n",
+			c.state.Generation, c.state.Restart+1, run.Lambda, fitness[best], fitness[order[run.Lambda/2]], run.Sigma, opts.curriculum.describe())
+		err = logGeneration(opts, c.state.Generation, seeds, map[string]float64{
+			runs.MetricScore:   fitness[best],
+			runs.MetricSuccess: successRate(successes),
+			"median":           fitness[order[run.Lambda/2]],
+			"sigma":            run.Sigma,
+			"run":              float64(c.state.Restart + 1),
+			"population":       float64(run.Lambda),
+		})
+		if err != nil {
+			return err
+		}
+		if fitness[best] > run.Best {
+			run.Best = fitness[best]
+			run.BestGeneration = run.Generation
+		}
+		// Seeds change every generation, so a new best is only saved if it beats the saved one on its seeds
+		if c.state.BestParameters == nil || fitness[best] > meanScore(c.tunable.WithParameters(c.state.BestParameters), seeds, opts) {
+			c.state.BestParameters = candidates[best]
+			if err := c.tunable.WithParameters(candidates[best]).Save(opts.ModelPath); err != nil {
+				return err
+			}
+			fmt.Println("BEST CONTROLLER SAVED:", opts.ModelPath)
+		}
+
+		if reason := c.stopReason(); reason != "" && c.state.Restart < c.settings.Restarts {
+			c.state.Restart++
+			fmt.Printf("RESTART %v: run %s, population doubled to %v\n", c.state.Restart, reason, 2*run.Lambda)
+			c.startRun(c.state.Initial, 2*run.Lambda)
+			c.constants()
+		}
+		opts.curriculum.advance()
+		if checkpointDue(opts, c.state.Generation) {
+			if err := saveCheckpoint(opts, c.state.Generation, c.source, c.state); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// startRun starts a new run around mean with lambda candidates per generation
+func (c *cmaes) startRun(mean []float64, lambda int) {
+	n := len(mean)
+	c.state.Run = cmaesRun{
+		Lambda: lambda,
+		Mean:   append([]float64(nil), mean...),
+		Sigma:  c.settings.Sigma,
+		C:      identity(n),
+		B:      identity(n),
+		D:      make([]float64, n),
+		PC:     make([]float64, n),
+		PS:     make([]float64, n),
+		Best:   -math.MaxFloat64,
+	}
+	for i := range c.state.Run.D {
+		c.state.Run.D[i] = 1
+	}
+}
+
+// constants sets the learning rates of the current run, as in Hansen's tutorial
+func (c *cmaes) constants() {
+	n := float64(len(c.state.Run.Mean))
+	c.mu = c.state.Run.Lambda / 2
+	c.weights = make([]float64, c.mu)
+	sum, squares := 0.0, 0.0
+	for i := range c.weights {
+		c.weights[i] = math.Log(float64(c.mu)+0.5) - math.Log(float64(i+1))
+		sum += c.weights[i]
+	}
+	for i := range c.weights {
+		c.weights[i] /= sum
+		squares += c.weights[i] * c.weights[i]
+	}
+	c.mueff = 1 / squares
+	c.cc = (4 + c.mueff/n) / (n + 4 + 2*c.mueff/n)
+	c.cs = (c.mueff + 2) / (n + c.mueff + 5)
+	c.c1 = 2 / ((n+1.3)*(n+1.3) + c.mueff)
+	c.cmu = math.Min(1-c.c1, 2*(c.mueff-2+1/c.mueff)/((n+2)*(n+2)+c.mueff))
+	c.damps = 1 + 2*math.Max(0, math.Sqrt((c.mueff-1)/(n+1))-1) + c.cs
+	c.chiN = math.Sqrt(n) * (1 - 1/(4*n) + 1/(21*n*n))
+}
+
+// updateEigen decomposes C again when it changed enough since last time, which is costly for many parameters
+func (c *cmaes) updateEigen() {
+	run := &c.state.Run
+	n := float64(len(run.Mean))
+	if float64(run.Generation-run.EigenGeneration) < 1/((c.c1+c.cmu)*n*10) {
+		return
+	}
+	run.EigenGeneration = run.Generation
+	values, vectors := eigen(run.C)
+	run.B = vectors
+	for i, value := range values {
+		run.D[i] = math.Sqrt(math.Max(value, 1e-20))
+	}
+}
+
+// update moves the mean towards the best candidates and adapts C and Sigma, order sorts candidates from best to worst
+func (c *cmaes) update(candidates, steps [][]float64, order []int) {
+	run := &c.state.Run
+	n := len(run.Mean)
+	step := make([]float64, n)
+	for i := 0; i < c.mu; i++ {
+		for j := range step {
+			step[j] += c.weights[i] * steps[order[i]][j]
+		}
+	}
+	for j := range run.Mean {
+		run.Mean[j] += run.Sigma * step[j]
+	}
+
+	// C^-1/2 * step = B * D^-1 * B^T * step
+	whitened := make([]float64, n)
+	for i := range whitened {
+		for j := 0; j < n; j++ {
+			whitened[i] += run.B[j][i] * step[j]
+		}
+		whitened[i] /= run.D[i]
+	}
+	whitened = multiply(run.B, whitened)
+	for i := range run.PS {
+		run.PS[i] = (1-c.cs)*run.PS[i] + math.Sqrt(c.cs*(2-c.cs)*c.mueff)*whitened[i]
+	}
+	psNorm := norm(run.PS)
+	hsig := 0.0
+	if psNorm/math.Sqrt(1-math.Pow(1-c.cs, 2*float64(run.Generation)))/c.chiN < 1.4+2/float64(n+1) {
+		hsig = 1
+	}
+	for i := range run.PC {
+		run.PC[i] = (1-c.cc)*run.PC[i] + hsig*math.Sqrt(c.cc*(2-c.cc)*c.mueff)*step[i]
+	}
+	for i := range run.C {
+		for j := range run.C[i] {
+			rankMu := 0.0
+			for k := 0; k < c.mu; k++ {
+				rankMu += c.weights[k] * steps[order[k]][i] * steps[order[k]][j]
+			}
+			run.C[i][j] = (1-c.c1-c.cmu)*run.C[i][j] +
+				c.c1*(run.PC[i]*run.PC[j]+(1-hsig)*c.cc*(2-c.cc)*run.C[i][j]) +
+				c.cmu*rankMu
+		}
+	}
+	run.Sigma *= math.Exp(c.cs / c.damps * (psNorm/c.chiN - 1))
+}
+
+// stopReason returns why the current run should be restarted, or an empty string if it should go on
+func (c *cmaes) stopReason() string {
+	run := &c.state.Run
+	maxD := 0.0
+	for _, d := range run.D {
+		maxD = math.Max(maxD, d)
+	}
+	switch {
+	case run.Sigma*maxD < c.settings.TolX:
+		return "converged"
+	case c.settings.StallGenerations > 0 && run.Generation-run.BestGeneration >= c.settings.StallGenerations:
+		return "stalled"
+	}
+	return ""
+}
+
+// multiply returns m * v
+func multiply(m [][]float64, v []float64) []float64 {
+	result := make([]float64, len(m))
+	for i, row := range m {
+		for j, x := range row {
+			result[i] += x * v[j]
+		}
+	}
+	return result
+}
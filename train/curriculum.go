@@ -0,0 +1,142 @@
+package train
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// CurriculumStage is a kind of scenario flown until the controller lands enough of them
+type CurriculumStage struct {
+	Name         string
+	Level        int              // sim package level flown
+	MaxLean      float32          // largest absolute lean (see sim.AscentProfile) of the seeds flown, 1 flies every seed
+	MaxLength    float32          // largest ascension length (see sim.AscentProfile) of the seeds flown, lower ascends less high
+	Disturbances sim.Disturbances // wind and sensor noise of every flight
+	SuccessRate  float64          // rolling success rate that advances to the next stage, the last stage never ends
+}
+
+// CurriculumSettings configure the stages a trainer goes through, from easy to hard
+type CurriculumSettings struct {
+	Enabled bool              // off flies every flight at Options.Level, on ignores it
+	Window  int               // last flights the rolling success rate is computed over
+	Stages  []CurriculumStage // in order, the first is flown from the start
+}
+
+// DefaultCurriculumSettings start with short, upright ascensions and unlimited ignitions
+// and end on the pad with wind and noisy sensors
+var DefaultCurriculumSettings = CurriculumSettings{
+	Window: 100,
+	Stages: []CurriculumStage{
+		{Name: "low and upright", Level: sim.LevelUnlimitedIgnitions, MaxLean: 0.3, MaxLength: -0.5, SuccessRate: 0.8},
+		{Name: "leaning", Level: sim.LevelUnlimitedIgnitions, MaxLean: 0.7, MaxLength: 0, SuccessRate: 0.8},
+		{Name: "any ascension", Level: sim.LevelUnlimitedIgnitions, MaxLean: 1, MaxLength: 1, SuccessRate: 0.8},
+		{Name: "two ignitions", Level: sim.LevelTwoIgnitions, MaxLean: 1, MaxLength: 1, SuccessRate: 0.7},
+		{Name: "pad", Level: sim.LevelPad, MaxLean: 1, MaxLength: 1, SuccessRate: 0.7},
+		{Name: "wind and noise", Level: sim.LevelPad, MaxLean: 1, MaxLength: 1, Disturbances: sim.Disturbances{Wind: 0.3, Gusts: 0.3, SensorNoise: 0.5}},
+	},
+}
+
+// curriculum is the current stage of a training run, shared by every copy of its Options
+//
+// It only changes between generations, while no flight is in the air
+type curriculum struct {
+	settings CurriculumSettings
+	stage    int
+	results  []bool // success of the last flights of the stage, at most Window
+}
+
+func newCurriculum(settings CurriculumSettings) *curriculum {
+	if !settings.Enabled || len(settings.Stages) == 0 {
+		return nil
+	}
+	return &curriculum{settings: settings}
+}
+
+// newEpisode creates the flight of seed at opts' level and limits, or at the current curriculum stage
+func newEpisode(seed int, opts Options) *sim.Episode {
+	level := opts.Level
+	c := opts.curriculum
+	if c != nil {
+		level = c.settings.Stages[c.stage].Level
+	}
+	episode := sim.NewEpisode(seed, level)
+	episode.Limits = opts.Limits
+	if c != nil {
+		episode.Disturbances = c.settings.Stages[c.stage].Disturbances
+	}
+	return episode
+}
+
+// maxSeedDraws is how many seeds are drawn for every seed a stage needs before its limits are taken as unreachable
+const maxSeedDraws = 1000
+
+// seeds returns n seeds drawn from rng whose ascension fits the current stage, or an error if too few of them do
+func (c *curriculum) seeds(rng *rand.Rand, n int) ([]int, error) {
+	if c == nil {
+		return flightSeeds(rng, n), nil
+	}
+	stage := c.settings.Stages[c.stage]
+	seeds := make([]int, 0, n)
+	for draws := 0; len(seeds) < n; draws++ {
+		if draws == n*maxSeedDraws {
+			return nil, fmt.Errorf("curriculum stage %q found only %v of %v seeds within MaxLean %v and MaxLength %v in %v draws",
+				stage.Name, len(seeds), n, stage.MaxLean, stage.MaxLength, draws)
+		}
+		seed := rng.Intn(maxSeed) + 1
+		lean, length := sim.AscentProfile(float32(seed))
+		if float32(math.Abs(float64(lean))) <= stage.MaxLean && length <= stage.MaxLength {
+			seeds = append(seeds, seed)
+		}
+	}
+	return seeds, nil
+}
+
+// record adds whether each of a generation's flights was a sim.OutcomeSuccess to the rolling success rate
+//
+// Scores are not enough, missing the pad does not make LandingScore negative
+func (c *curriculum) record(successes []bool) {
+	if c == nil {
+		return
+	}
+	c.results = append(c.results, successes...)
+	if len(c.results) > c.settings.Window {
+		c.results = c.results[len(c.results)-c.settings.Window:]
+	}
+}
+
+// advance moves to the next stage once the rolling success rate over a full Window reaches the current one's SuccessRate
+func (c *curriculum) advance() {
+	if c == nil || c.stage == len(c.settings.Stages)-1 || len(c.results) < c.settings.Window {
+		return
+	}
+	if c.successRate() >= c.settings.Stages[c.stage].SuccessRate {
+		c.stage++
+		c.results = nil
+		fmt.Printf("CURRICULUM: advanced to stage %v/%v %v\n", c.stage+1, len(c.settings.Stages), c.settings.Stages[c.stage].Name)
+	}
+}
+
+func (c *curriculum) successRate() float64 {
+	if len(c.results) == 0 {
+		return 0
+	}
+	successes := 0
+	for _, success := range c.results {
+		if success {
+			successes++
+		}
+	}
+	return float64(successes) / float64(len(c.results))
+}
+
+// describe returns the stage and rolling success rate to append to a generation's log, empty without a curriculum
+func (c *curriculum) describe() string {
+	if c == nil {
+		return ""
+	}
+	return fmt.Sprintf(" stage %v/%v %v rolling success %.1f%%",
+		c.stage+1, len(c.settings.Stages), c.settings.Stages[c.stage].Name, 100*c.successRate())
+}
@@ -0,0 +1,313 @@
+package train
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/renatobrittoaraujo/rl/input"
+	"github.com/renatobrittoaraujo/rl/nn"
+	"github.com/renatobrittoaraujo/rl/runs"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// ImitationSettings holds the hyperparameters of behavior cloning and DAgger
+type ImitationSettings struct {
+	Demonstrations   string // directory of recorded flights (see input.Demonstration), may be empty when DAgger is on
+	SuccessfulOnly   bool   // clones only successful landings
+	HiddenLayers     []int  // sizes of the hidden layers of the network
+	LearningRate     float64
+	MinibatchSize    int // samples per gradient step
+	DAggerIterations int // rounds of flying the network and asking the autopilot what it would have done, 0 only clones
+	DAggerEpisodes   int // episodes flown per DAgger round
+}
+
+// DefaultImitationSettings clone successful flights without DAgger
+var DefaultImitationSettings = ImitationSettings{
+	SuccessfulOnly:   true,
+	HiddenLayers:     []int{32, 32},
+	LearningRate:     1e-3,
+	MinibatchSize:    256,
+	DAggerIterations: 0,
+	DAggerEpisodes:   20,
+}
+
+// imitationSample is an observation and the action vector (see input.ActionVector) to imitate on it
+type imitationSample struct {
+	Observation []float64 // not normalized
+	Target      []float64
+}
+
+// imitationCheckpoint is the state of imitation between DAgger rounds (see checkpoint)
+type imitationCheckpoint struct {
+	Network   *nn.Network
+	Adam      *nn.Adam
+	Mean, Std []float64
+	Samples   []imitationSample
+}
+
+type imitation struct {
+	opts      Options
+	settings  ImitationSettings
+	rng       *rand.Rand
+	source    *source
+	network   *nn.Network
+	adam      *nn.Adam
+	mean, std []float64
+	samples   []imitationSample
+}
+
+// runImitation fits a network to demonstrations for opts.Generations epochs, then for every
+// DAgger round flies it, labels every observation it visited with the autopilot's
+// action, adds them to the dataset and fits it again
+func runImitation(opts Options) error {
+	s := opts.Imitation
+	if s.MinibatchSize < 1 || (s.DAggerIterations > 0 && s.DAggerEpisodes < 1) {
+		return fmt.Errorf("imitation needs a minibatch size of at least 1 and at least 1 episode per DAgger round")
+	}
+	im := &imitation{opts: opts, settings: s}
+	done := 0
+	if opts.Resume != "" {
+		var state imitationCheckpoint
+		var err error
+		if done, im.rng, im.source, err = loadCheckpoint(opts, &state); err != nil {
+			return err
+		}
+		im.network, im.adam, im.mean, im.std, im.samples = state.Network, state.Adam, state.Mean, state.Std, state.Samples
+	} else if err := im.clone(); err != nil {
+		return err
+	}
+
+	for round := done + 1; round <= s.DAggerIterations; round++ {
+		added, score, successes, err := im.dagger()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("DAGGER ROUND %v: mean score %.3f success %.1f%% samples added %v dataset %v%v\n",
+			round, score, 100*successes, added, len(im.samples), opts.curriculum.describe())
+		im.normalization()
+		loss := im.fit(round)
+		err = logGeneration(opts, round, nil, map[string]float64{
+			runs.MetricScore:   score,
+			runs.MetricSuccess: successes,
+			"samples_added":    float64(added),
+			"samples":          float64(len(im.samples)),
+			"loss":             loss,
+		})
+		if err != nil {
+			return err
+		}
+		opts.curriculum.advance()
+		if err := im.model().Save(opts.ModelPath); err != nil {
+			return err
+		}
+		if err := im.checkpoint(round); err != nil {
+			return err
+		}
+	}
+	fmt.Println("MODEL SAVED:", opts.ModelPath)
+	return nil
+}
+
+// clone creates the network and fits it to the demonstrations, if there are any
+func (im *imitation) clone() error {
+	s := im.settings
+	im.rng, im.source = newRand(im.opts.Seed, 0)
+	if s.Demonstrations != "" {
+		if err := im.loadDemonstrations(); err != nil {
+			return err
+		}
+	}
+	if len(im.samples) == 0 && s.DAggerIterations == 0 {
+		return errors.New("imitation has no demonstrations to clone and DAgger is off")
+	}
+
+	inputs := len(input.ObservationLayout)
+	sizes := layerSizes(inputs, s.HiddenLayers, len(input.ActionLayout))
+	layerActivations := activations(len(s.HiddenLayers))
+	// Every action vector target is in [-1.0, 1.0]
+	layerActivations[len(layerActivations)-1] = nn.Tanh
+	im.network = nn.NewNetwork(sizes, layerActivations, im.rng)
+	im.adam = nn.NewAdam(im.network, s.LearningRate)
+	if len(im.samples) == 0 {
+		// Nothing to clone, the first DAgger round flies the untrained network
+		im.mean, im.std = observationStats(flightSeeds(im.rng, s.DAggerEpisodes), im.opts)
+	} else {
+		im.normalization()
+		loss := im.fit(0)
+		if err := logGeneration(im.opts, 0, nil, map[string]float64{"loss": loss, "samples": float64(len(im.samples))}); err != nil {
+			return err
+		}
+	}
+	if err := im.model().Save(im.opts.ModelPath); err != nil {
+		return err
+	}
+	return im.checkpoint(0)
+}
+
+// checkpoint saves the state after round if a checkpoint is due, cloning counts as round 0
+func (im *imitation) checkpoint(round int) error {
+	if im.settings.DAggerIterations == 0 || !checkpointDue(im.opts, round) {
+		return nil
+	}
+	state := imitationCheckpoint{Network: im.network, Adam: im.adam, Mean: im.mean, Std: im.std, Samples: im.samples}
+	return saveCheckpoint(im.opts, round, im.source, state)
+}
+
+// loadDemonstrations adds every step of the demonstrations to the dataset
+func (im *imitation) loadDemonstrations() error {
+	demonstrations, err := input.LoadDemonstrations(im.settings.Demonstrations)
+	if err != nil {
+		return err
+	}
+	used := 0
+	for _, d := range demonstrations {
+		if im.settings.SuccessfulOnly && d.Outcome != sim.OutcomeSuccess.String() {
+			continue
+		}
+		if d.SimVersion != sim.Version || d.Vehicle != sim.DefaultVehicle || d.Physics != sim.DefaultPhysics {
+			fmt.Printf("Warning: a demonstration of seed %v was flown on a different simulation, vehicle or physics\n", d.Seed)
+		}
+		for _, step := range d.Steps {
+			im.samples = append(im.samples, imitationSample{
+				Observation: input.ObservationVector(step.Observation),
+				Target:      input.ActionVector(step.Action),
+			})
+		}
+		used++
+	}
+	fmt.Printf("DEMONSTRATIONS: %v of %v used, %v samples\n", used, len(demonstrations), len(im.samples))
+	return nil
+}
+
+// normalization sets the observation mean and standard deviation of the dataset
+func (im *imitation) normalization() {
+	size := len(input.ObservationLayout)
+	im.mean = make([]float64, size)
+	im.std = make([]float64, size)
+	for _, sample := range im.samples {
+		for i, value := range sample.Observation {
+			im.mean[i] += value
+			im.std[i] += value * value
+		}
+	}
+	n := float64(len(im.samples))
+	for i := range im.mean {
+		im.mean[i] /= n
+		variance := im.std[i]/n - im.mean[i]*im.mean[i]
+		im.std[i] = 1
+		if variance > 1e-6 {
+			im.std[i] = math.Sqrt(variance)
+		}
+	}
+}
+
+// fit runs opts.Generations epochs of minibatch gradient descent on the mean squared error over the dataset,
+// returning the loss of the last epoch
+func (im *imitation) fit(round int) (loss float64) {
+	grads := nn.NewGradients(im.network)
+	size := im.settings.MinibatchSize
+	for epoch := 1; epoch <= im.opts.Generations; epoch++ {
+		order := im.rng.Perm(len(im.samples))
+		loss = 0
+		for start := 0; start < len(order); start += size {
+			end := start + size
+			if end > len(order) {
+				end = len(order)
+			}
+			grads.Zero()
+			batch := float64(end - start)
+			for _, index := range order[start:end] {
+				sample := im.samples[index]
+				out, trace := im.network.ForwardTrace(im.normalize(sample.Observation))
+				gradient := make([]float64, len(out))
+				for i := range out {
+					difference := out[i] - sample.Target[i]
+					loss += difference * difference
+					gradient[i] = 2 * difference / batch
+				}
+				im.network.Backward(trace, gradient, grads)
+			}
+			im.adam.Update(im.network, grads)
+		}
+		loss /= float64(len(im.samples))
+		fmt.Printf("EPOCH %v (ROUND %v): samples %v loss %.5f\n", epoch, round, len(im.samples), loss)
+	}
+	return loss
+}
+
+func (im *imitation) normalize(observation []float64) []float64 {
+	normalized := make([]float64, len(observation))
+	for i, value := range observation {
+		normalized[i] = (value - im.mean[i]) / im.std[i]
+	}
+	return normalized
+}
+
+// dagger flies the current network on DAggerEpisodes seeds in parallel and adds every observation
+// it met, labeled with the action of the autopilot, returning how many samples were
+// added and the mean score and success rate of the flights
+func (im *imitation) dagger() (added int, score, successes float64, err error) {
+	manager, err := input.CreateAI(im.model())
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	expert, inputErr := input.CreateInput(input.AutopilotInput)
+	if inputErr {
+		return 0, 0, 0, errors.New("autopilot input has not been initalized correctly")
+	}
+	n := im.settings.DAggerEpisodes
+	seeds, err := im.opts.curriculum.seeds(im.rng, n)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if err := im.opts.Run.Flew(seeds); err != nil {
+		return 0, 0, 0, err
+	}
+	visited := make([][]imitationSample, n)
+	scores := make([]float64, n)
+	landed := make([]bool, n)
+	parallel(n, im.opts.Workers, func(i int) {
+		episode := newEpisode(seeds[i], im.opts)
+		labeler := labeler{pilot: manager, expert: expert}
+		episode.Run(&labeler)
+		visited[i], scores[i], landed[i] = labeler.samples, terminalScore(episode), episode.Outcome == sim.OutcomeSuccess
+	})
+	im.opts.curriculum.record(landed)
+	for i := range visited {
+		im.samples = append(im.samples, visited[i]...)
+		added += len(visited[i])
+		score += scores[i]
+		if landed[i] {
+			successes++
+		}
+	}
+	return added, score / float64(n), successes / float64(n), nil
+}
+
+// labeler flies pilot and keeps every observation with what expert would have done instead
+type labeler struct {
+	pilot, expert input.Manager
+	samples       []imitationSample
+}
+
+func (l *labeler) Act(obs sim.Observation) sim.Action {
+	l.samples = append(l.samples, imitationSample{
+		Observation: input.ObservationVector(obs),
+		Target:      input.ActionVector(l.expert.Act(obs)),
+	})
+	return l.pilot.Act(obs)
+}
+
+// model returns the network as a model executable by the ai input
+func (im *imitation) model() *nn.Model {
+	network := nn.Network{}
+	for _, layer := range im.network.Layers {
+		network.Layers = append(network.Layers, layer.Clone())
+	}
+	model := nn.NewModel(network, input.ObservationLayout, input.ActionLayout)
+	model.InputMean = im.mean
+	model.InputStd = im.std
+	return model
+}
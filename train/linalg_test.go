@@ -0,0 +1,58 @@
+package train
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestEigen checks that eigen decomposes symmetric matrices into orthonormal eigenvectors
+// whose eigenvalues rebuild the matrix
+func TestEigen(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	matrices := map[string][][]float64{
+		"1x1":      {{3}},
+		"diagonal": {{2, 0, 0}, {0, -1, 0}, {0, 0, 5}},
+		"repeated": {{2, 1, 1}, {1, 2, 1}, {1, 1, 2}},
+		"singular": {{1, 1}, {1, 1}},
+	}
+	for _, n := range []int{2, 5, 10} {
+		a := make([][]float64, n)
+		for i := range a {
+			a[i] = make([]float64, n)
+		}
+		for i := 0; i < n; i++ {
+			for j := i; j < n; j++ {
+				a[i][j] = rng.NormFloat64()
+				a[j][i] = a[i][j]
+			}
+		}
+		matrices[fmt.Sprintf("random %vx%v", n, n)] = a
+	}
+	for name, a := range matrices {
+		t.Run(name, func(t *testing.T) {
+			n := len(a)
+			values, vectors := eigen(a)
+			for i := 0; i < n; i++ {
+				for j := 0; j < n; j++ {
+					rebuilt, inner := 0.0, 0.0
+					for k := 0; k < n; k++ {
+						rebuilt += vectors[i][k] * values[k] * vectors[j][k]
+						inner += vectors[k][i] * vectors[k][j]
+					}
+					if math.Abs(rebuilt-a[i][j]) > 1e-9 {
+						t.Errorf("rebuilt [%v][%v] is %v, want %v", i, j, rebuilt, a[i][j])
+					}
+					want := 0.0
+					if i == j {
+						want = 1
+					}
+					if math.Abs(inner-want) > 1e-9 {
+						t.Errorf("eigenvectors %v and %v have inner product %v, want %v", i, j, inner, want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,713 @@
+package train
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/renatobrittoaraujo/rl/input"
+	"github.com/renatobrittoaraujo/rl/nn"
+	"github.com/renatobrittoaraujo/rl/runs"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// NEATSettings holds the hyperparameters of NEAT (NeuroEvolution of Augmenting Topologies)
+type NEATSettings struct {
+	CompatibilityThreshold float64 // genomes closer than this belong to the same species
+	ExcessCoefficient      float64 // weight of excess genes in the compatibility distance
+	DisjointCoefficient    float64 // weight of disjoint genes in the compatibility distance
+	WeightCoefficient      float64 // weight of the mean weight difference of matching genes in the compatibility distance
+	WeightMutationRate     float64 // chance the weights of an offspring are mutated
+	WeightPerturbation     float64 // standard deviation of weight mutations
+	WeightResetRate        float64 // chance a mutated weight is replaced instead of perturbed
+	AddConnectionRate      float64 // chance an offspring gains a connection
+	AddNodeRate            float64 // chance an offspring splits a connection with a new node
+	CrossoverRate          float64 // chance an offspring has two parents instead of one
+	SurvivalRate           float64 // fraction of the best of each species allowed to reproduce
+	Stagnation             int     // generations a species may go without improving before it is dropped
+}
+
+// DefaultNEATSettings are the hyperparameters of the original NEAT paper
+var DefaultNEATSettings = NEATSettings{
+	CompatibilityThreshold: 3,
+	ExcessCoefficient:      1,
+	DisjointCoefficient:    1,
+	WeightCoefficient:      0.4,
+	WeightMutationRate:     0.8,
+	WeightPerturbation:     0.5,
+	WeightResetRate:        0.1,
+	AddConnectionRate:      0.05,
+	AddNodeRate:            0.03,
+	CrossoverRate:          0.75,
+	SurvivalRate:           0.2,
+	Stagnation:             15,
+}
+
+type nodeKind int
+
+const (
+	inputNode nodeKind = iota
+	biasNode
+	outputNode
+	hiddenNode
+)
+
+type nodeGene struct {
+	ID   int
+	Kind nodeKind
+}
+
+type connectionGene struct {
+	In, Out    int
+	Weight     float64
+	Enabled    bool
+	Innovation int
+}
+
+// genome is a network of nodes and connections, connections are sorted by innovation
+type genome struct {
+	Nodes       []nodeGene
+	Connections []connectionGene
+	Fitness     float64 // mean score over the generation's seeds
+}
+
+// innovations numbers structural mutations so the same mutation in different genomes gets the same number
+type innovations struct {
+	connections map[[2]int]int // innovation of the connection between two nodes
+	splits      map[int]int    // node created by splitting a connection innovation
+	nextGene    int
+	nextNode    int
+}
+
+func (in *innovations) connection(from, to int) int {
+	key := [2]int{from, to}
+	if innovation, ok := in.connections[key]; ok {
+		return innovation
+	}
+	in.connections[key] = in.nextGene
+	in.nextGene++
+	return in.nextGene - 1
+}
+
+// split returns the node created by splitting connection innovation in g
+func (in *innovations) split(g *genome, innovation int) int {
+	if node, ok := in.splits[innovation]; ok && !g.hasNode(node) {
+		return node
+	}
+	// Either a new split or g split this connection before, which needs a node of its own
+	in.splits[innovation] = in.nextNode
+	in.nextNode++
+	return in.nextNode - 1
+}
+
+type species struct {
+	representative *genome
+	members        []*genome
+	best           float64 // best fitness ever reached by a member
+	stagnant       int     // generations since best improved
+}
+
+// neatCheckpoint is the state of a NEAT run between generations (see checkpoint)
+type neatCheckpoint struct {
+	Population  []*genome
+	Species     []speciesCheckpoint
+	Connections [][3]int // from, to and innovation of every connection ever created
+	Splits      [][2]int // connection innovation and the node last created by splitting it
+	NextGene    int
+	NextNode    int
+	Mean, Std   []float64 // observation normalization
+	Best        *genome   // saved as the model
+}
+
+type speciesCheckpoint struct {
+	Representative *genome
+	Best           float64
+	Stagnant       int
+}
+
+// neat holds the state of a NEAT run
+type neat struct {
+	opts        Options
+	settings    NEATSettings
+	rng         *rand.Rand
+	source      *source
+	innovations innovations
+	population  []*genome
+	species     []*species
+	inputs      int
+	outputs     int
+}
+
+func newNEAT(opts Options) *neat {
+	n := &neat{
+		opts:     opts,
+		settings: opts.NEAT,
+		inputs:   len(input.ObservationLayout),
+		outputs:  len(input.ActionLayout),
+	}
+	n.rng, n.source = newRand(opts.Seed, 0)
+	n.innovations = innovations{
+		connections: map[[2]int]int{},
+		splits:      map[int]int{},
+		nextNode:    n.inputs + 1 + n.outputs,
+	}
+	// Every genome starts with no hidden nodes, inputs and bias fully connected to outputs
+	for i := 0; i < opts.Population; i++ {
+		g := &genome{}
+		for id := 0; id < n.innovations.nextNode; id++ {
+			g.Nodes = append(g.Nodes, nodeGene{ID: id, Kind: n.kind(id)})
+		}
+		for from := 0; from <= n.inputs; from++ {
+			for to := n.inputs + 1; to < n.inputs+1+n.outputs; to++ {
+				g.Connections = append(g.Connections, connectionGene{
+					In:         from,
+					Out:        to,
+					Weight:     n.rng.NormFloat64(),
+					Enabled:    true,
+					Innovation: n.innovations.connection(from, to),
+				})
+			}
+		}
+		n.population = append(n.population, g)
+	}
+	return n
+}
+
+// kind returns the kind of the node with id among the initial nodes, inputs come first, then bias and outputs
+func (n *neat) kind(id int) nodeKind {
+	switch {
+	case id < n.inputs:
+		return inputNode
+	case id == n.inputs:
+		return biasNode
+	case id <= n.inputs+n.outputs:
+		return outputNode
+	}
+	return hiddenNode
+}
+
+func runNEAT(opts Options) error {
+	if opts.Population < 2 {
+		return fmt.Errorf("NEAT needs a population of at least 2, got %v", opts.Population)
+	}
+	if opts.SeedsPerEvaluation < 1 {
+		return fmt.Errorf("NEAT needs at least 1 seed per evaluation, got %v", opts.SeedsPerEvaluation)
+	}
+	var n *neat
+	var mean, std []float64
+	var best *genome
+	done := 0
+	if opts.Resume != "" {
+		var state neatCheckpoint
+		n = &neat{opts: opts, settings: opts.NEAT, inputs: len(input.ObservationLayout), outputs: len(input.ActionLayout)}
+		var err error
+		if done, n.rng, n.source, err = loadCheckpoint(opts, &state); err != nil {
+			return err
+		}
+		n.restore(state)
+		mean, std, best = state.Mean, state.Std, state.Best
+	} else {
+		n = newNEAT(opts)
+		mean, std = observationStats(flightSeeds(n.rng, opts.SeedsPerEvaluation), opts)
+	}
+	for generation := done + 1; generation <= opts.Generations; generation++ {
+		seeds, err := opts.curriculum.seeds(n.rng, opts.SeedsPerEvaluation)
+		if err != nil {
+			return err
+		}
+		n.evaluate(seeds, mean, std)
+		n.speciate()
+
+		champion := n.population[0]
+		total := 0.0
+		for _, g := range n.population {
+			total += g.Fitness
+			if g.Fitness > champion.Fitness {
+				champion = g
+			}
+		}
+		hidden := len(champion.Nodes) - n.inputs - 1 - n.outputs
+		successes := flightSuccesses(n.controller(champion, mean, std), seeds, opts)
+		if opts.curriculum != nil {
+			opts.curriculum.record(successes)
+		}
+		fmt.Printf("GENERATION %v: best %.3f mean %.3f species %v champion hidden nodes %v connections %v%v\n",
+			generation, champion.Fitness, total/float64(len(n.population)), len(n.species), hidden, len(champion.Connections), opts.curriculum.describe())
+		err = logGeneration(opts, generation, seeds, map[string]float64{
+			runs.MetricScore:   champion.Fitness,
+			runs.MetricSuccess: successRate(successes),
+			"mean":             total / float64(len(n.population)),
+			"species":          float64(len(n.species)),
+			"hidden_nodes":     float64(hidden),
+			"connections":      float64(len(champion.Connections)),
+		})
+		if err != nil {
+			return err
+		}
+		// Seeds change every generation, so a new champion is only saved if it beats the last one on its seeds
+		if best == nil || champion.Fitness > meanScore(n.controller(best, mean, std), seeds, opts) {
+			best = champion
+			if err := n.model(best, mean, std).Save(opts.ModelPath); err != nil {
+				return err
+			}
+			fmt.Println("BEST MODEL SAVED:", opts.ModelPath)
+		}
+		opts.curriculum.advance()
+		// Also after the last generation, so a checkpoint always resumes with the next generation
+		n.reproduce()
+		if checkpointDue(opts, generation) {
+			state := n.checkpoint()
+			state.Mean, state.Std, state.Best = mean, std, best
+			if err := saveCheckpoint(opts, generation, n.source, state); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkpoint returns the population, species and innovations of n
+func (n *neat) checkpoint() neatCheckpoint {
+	state := neatCheckpoint{Population: n.population, NextGene: n.innovations.nextGene, NextNode: n.innovations.nextNode}
+	for _, s := range n.species {
+		state.Species = append(state.Species, speciesCheckpoint{Representative: s.representative, Best: s.best, Stagnant: s.stagnant})
+	}
+	for key, innovation := range n.innovations.connections {
+		state.Connections = append(state.Connections, [3]int{key[0], key[1], innovation})
+	}
+	for innovation, node := range n.innovations.splits {
+		state.Splits = append(state.Splits, [2]int{innovation, node})
+	}
+	// Sorted so the same state is always written the same way
+	sort.Slice(state.Connections, func(i, j int) bool { return state.Connections[i][2] < state.Connections[j][2] })
+	sort.Slice(state.Splits, func(i, j int) bool { return state.Splits[i][0] < state.Splits[j][0] })
+	return state
+}
+
+// restore sets the population, species and innovations of n from a checkpoint
+func (n *neat) restore(state neatCheckpoint) {
+	n.population = state.Population
+	n.species = nil
+	for _, s := range state.Species {
+		n.species = append(n.species, &species{representative: s.Representative, best: s.Best, stagnant: s.Stagnant})
+	}
+	n.innovations = innovations{
+		connections: map[[2]int]int{},
+		splits:      map[int]int{},
+		nextGene:    state.NextGene,
+		nextNode:    state.NextNode,
+	}
+	for _, c := range state.Connections {
+		n.innovations.connections[[2]int{c[0], c[1]}] = c[2]
+	}
+	for _, split := range state.Splits {
+		n.innovations.splits[split[0]] = split[1]
+	}
+}
+
+// evaluate sets the fitness of every genome, flying seeds in parallel
+func (n *neat) evaluate(seeds []int, mean, std []float64) {
+	parallel(len(n.population), n.opts.Workers, func(i int) {
+		g := n.population[i]
+		g.Fitness = meanScore(n.controller(g, mean, std), seeds, n.opts)
+	})
+}
+
+func (n *neat) controller(g *genome, mean, std []float64) input.Manager {
+	manager, err := input.CreateAI(n.model(g, mean, std))
+	if err != nil {
+		panic(err)
+	}
+	return manager
+}
+
+// model returns g as a model executable by the ai input
+func (n *neat) model(g *genome, mean, std []float64) *nn.Model {
+	model := nn.NewModel(n.network(g), input.ObservationLayout, input.ActionLayout)
+	model.InputMean = mean
+	model.InputStd = std
+	return model
+}
+
+// network converts g to a layered network that computes exactly the same outputs
+//
+// Nodes are layered by their longest path from the inputs, outputs all go in the last
+// layer. Connections that skip layers are carried by linear copies of their source
+// node through every layer in between, bias connections become layer biases
+func (n *neat) network(g *genome) nn.Network {
+	depth := map[int]int{}
+	var depthOf func(id int) int
+	depthOf = func(id int) int {
+		if d, ok := depth[id]; ok {
+			return d
+		}
+		d := 0
+		if id > n.inputs {
+			d = 1
+			for _, c := range g.Connections {
+				if c.Enabled && c.Out == id && c.In != n.inputs {
+					if from := depthOf(c.In) + 1; from > d {
+						d = from
+					}
+				}
+			}
+		}
+		depth[id] = d
+		return d
+	}
+	layers := 1
+	for _, node := range g.Nodes {
+		d := depthOf(node.ID)
+		if node.Kind == hiddenNode && d+1 > layers {
+			layers = d + 1
+		} else if node.Kind == outputNode && d > layers {
+			layers = d
+		}
+	}
+	for _, node := range g.Nodes {
+		if node.Kind == outputNode {
+			depth[node.ID] = layers
+		}
+	}
+
+	// lastUse is the last layer a node's value is read from
+	lastUse := map[int]int{}
+	for _, c := range g.Connections {
+		if c.Enabled && c.In != n.inputs && depth[c.Out]-1 > lastUse[c.In] {
+			lastUse[c.In] = depth[c.Out] - 1
+		}
+	}
+
+	// slots[l] is the node behind each value of layer l, layer 0 being the inputs
+	slots := make([][]int, layers+1)
+	copies := make([]map[int]bool, layers+1)
+	for id := 0; id < n.inputs; id++ {
+		slots[0] = append(slots[0], id)
+	}
+	for l := 1; l <= layers; l++ {
+		copies[l] = map[int]bool{}
+		for _, node := range g.Nodes {
+			if (node.Kind == hiddenNode || node.Kind == outputNode) && depth[node.ID] == l {
+				slots[l] = append(slots[l], node.ID)
+			}
+		}
+		for _, node := range g.Nodes {
+			if node.Kind != biasNode && node.Kind != outputNode && depth[node.ID] < l && lastUse[node.ID] >= l {
+				slots[l] = append(slots[l], node.ID)
+				copies[l][node.ID] = true
+			}
+		}
+	}
+
+	network := nn.Network{Layers: make([]nn.Layer, layers)}
+	for l := 1; l <= layers; l++ {
+		position := map[int]int{}
+		for i, id := range slots[l-1] {
+			position[id] = i
+		}
+		layer := nn.Layer{
+			Activation:  nn.Tanh,
+			Activations: make([]string, len(slots[l])),
+			Weights:     make([][]float64, len(slots[l])),
+			Biases:      make([]float64, len(slots[l])),
+		}
+		for o, id := range slots[l] {
+			layer.Weights[o] = make([]float64, len(slots[l-1]))
+			if copies[l][id] {
+				layer.Activations[o] = nn.Linear
+				layer.Weights[o][position[id]] = 1
+				continue
+			}
+			layer.Activations[o] = nn.Tanh
+			for _, c := range g.Connections {
+				if !c.Enabled || c.Out != id {
+					continue
+				}
+				if c.In == n.inputs {
+					layer.Biases[o] += c.Weight
+				} else {
+					layer.Weights[o][position[c.In]] += c.Weight
+				}
+			}
+		}
+		network.Layers[l-1] = layer
+	}
+	return network
+}
+
+// distance is the compatibility distance between two genomes, small distances mean similar topologies
+func (n *neat) distance(a, b *genome) float64 {
+	var excess, disjoint, matching int
+	weights := 0.0
+	i, j := 0, 0
+	for i < len(a.Connections) && j < len(b.Connections) {
+		ca, cb := a.Connections[i], b.Connections[j]
+		switch {
+		case ca.Innovation == cb.Innovation:
+			matching++
+			weights += math.Abs(ca.Weight - cb.Weight)
+			i++
+			j++
+		case ca.Innovation < cb.Innovation:
+			disjoint++
+			i++
+		default:
+			disjoint++
+			j++
+		}
+	}
+	excess = len(a.Connections) - i + len(b.Connections) - j
+	genes := float64(len(a.Connections))
+	if len(b.Connections) > len(a.Connections) {
+		genes = float64(len(b.Connections))
+	}
+	distance := (n.settings.ExcessCoefficient*float64(excess) + n.settings.DisjointCoefficient*float64(disjoint)) / genes
+	if matching > 0 {
+		distance += n.settings.WeightCoefficient * weights / float64(matching)
+	}
+	return distance
+}
+
+// speciate assigns every genome to the first species it is compatible with, creating species as needed
+func (n *neat) speciate() {
+	for _, s := range n.species {
+		s.members = nil
+	}
+	for _, g := range n.population {
+		var found *species
+		for _, s := range n.species {
+			if n.distance(g, s.representative) < n.settings.CompatibilityThreshold {
+				found = s
+				break
+			}
+		}
+		if found == nil {
+			found = &species{representative: g, best: math.Inf(-1)}
+			n.species = append(n.species, found)
+		}
+		found.members = append(found.members, g)
+	}
+	alive := n.species[:0]
+	for _, s := range n.species {
+		if len(s.members) == 0 {
+			continue
+		}
+		sort.SliceStable(s.members, func(i, j int) bool { return s.members[i].Fitness > s.members[j].Fitness })
+		if s.members[0].Fitness > s.best {
+			s.best = s.members[0].Fitness
+			s.stagnant = 0
+		} else {
+			s.stagnant++
+		}
+		alive = append(alive, s)
+	}
+	n.species = alive
+}
+
+// reproduce replaces the population with offspring of its species, each species having
+// as many offspring as its share of the total shared fitness
+func (n *neat) reproduce() {
+	// Stagnant species are dropped, unless they are among the two best
+	sort.SliceStable(n.species, func(i, j int) bool { return n.species[i].best > n.species[j].best })
+	kept := n.species[:0]
+	for i, s := range n.species {
+		if i < 2 || s.stagnant < n.settings.Stagnation {
+			kept = append(kept, s)
+		}
+	}
+	n.species = kept
+
+	// Fitness sharing: a species is worth the mean fitness of its members, shifted to be positive
+	shares := make([]float64, len(n.species))
+	total := 0.0
+	for i, s := range n.species {
+		for _, g := range s.members {
+			shares[i] += g.Fitness - sim.UnlandedScore
+		}
+		shares[i] /= float64(len(s.members))
+		total += shares[i]
+	}
+	offspring := make([]int, len(n.species))
+	assigned := 0
+	for i := range n.species {
+		if total > 0 {
+			offspring[i] = int(math.Floor(shares[i] / total * float64(n.opts.Population)))
+		} else {
+			offspring[i] = n.opts.Population / len(n.species)
+		}
+		assigned += offspring[i]
+	}
+	// Rounding leftovers go to the best species
+	offspring[0] += n.opts.Population - assigned
+
+	var population []*genome
+	for i, s := range n.species {
+		if offspring[i] == 0 {
+			continue
+		}
+		// The champion of every species that is not tiny survives unchanged
+		if len(s.members) >= 5 {
+			population = append(population, s.members[0].clone())
+			offspring[i]--
+		}
+		parents := int(math.Ceil(n.settings.SurvivalRate * float64(len(s.members))))
+		if parents < 1 {
+			parents = 1
+		}
+		for k := 0; k < offspring[i]; k++ {
+			var child *genome
+			mother := s.members[n.rng.Intn(parents)]
+			if parents > 1 && n.rng.Float64() < n.settings.CrossoverRate {
+				father := s.members[n.rng.Intn(parents)]
+				child = n.crossover(mother, father)
+			} else {
+				child = mother.clone()
+			}
+			n.mutate(child)
+			population = append(population, child)
+		}
+		s.representative = s.members[n.rng.Intn(len(s.members))]
+	}
+	n.population = population
+}
+
+// crossover returns a child with the structure of the fitter parent, matching genes come from either parent
+func (n *neat) crossover(a, b *genome) *genome {
+	if b.Fitness > a.Fitness {
+		a, b = b, a
+	}
+	other := map[int]connectionGene{}
+	for _, c := range b.Connections {
+		other[c.Innovation] = c
+	}
+	child := a.clone()
+	for i, c := range child.Connections {
+		match, ok := other[c.Innovation]
+		if !ok {
+			continue
+		}
+		if n.rng.Float64() < 0.5 {
+			child.Connections[i].Weight = match.Weight
+		}
+		// Genes disabled in either parent are likely to stay disabled
+		if !c.Enabled || !match.Enabled {
+			child.Connections[i].Enabled = n.rng.Float64() >= 0.75
+		}
+	}
+	return child
+}
+
+func (n *neat) mutate(g *genome) {
+	if n.rng.Float64() < n.settings.WeightMutationRate {
+		for i := range g.Connections {
+			if n.rng.Float64() < n.settings.WeightResetRate {
+				g.Connections[i].Weight = n.rng.NormFloat64()
+			} else {
+				g.Connections[i].Weight += n.rng.NormFloat64() * n.settings.WeightPerturbation
+			}
+		}
+	}
+	if n.rng.Float64() < n.settings.AddConnectionRate {
+		n.addConnection(g)
+	}
+	if n.rng.Float64() < n.settings.AddNodeRate {
+		n.addNode(g)
+	}
+}
+
+// addConnection connects two unconnected nodes, networks stay feedforward
+func (n *neat) addConnection(g *genome) {
+	for try := 0; try < 20; try++ {
+		from := g.Nodes[n.rng.Intn(len(g.Nodes))]
+		to := g.Nodes[n.rng.Intn(len(g.Nodes))]
+		if from.Kind == outputNode || to.Kind == inputNode || to.Kind == biasNode || from.ID == to.ID {
+			continue
+		}
+		if g.connected(from.ID, to.ID) || g.reaches(to.ID, from.ID) {
+			continue
+		}
+		g.addConnection(connectionGene{
+			In:         from.ID,
+			Out:        to.ID,
+			Weight:     n.rng.NormFloat64(),
+			Enabled:    true,
+			Innovation: n.innovations.connection(from.ID, to.ID),
+		})
+		return
+	}
+}
+
+// addNode splits an enabled connection in two with a new node in between, which starts as a near no-op
+func (n *neat) addNode(g *genome) {
+	var enabled []int
+	for i, c := range g.Connections {
+		if c.Enabled {
+			enabled = append(enabled, i)
+		}
+	}
+	if len(enabled) == 0 {
+		return
+	}
+	i := enabled[n.rng.Intn(len(enabled))]
+	split := g.Connections[i]
+	g.Connections[i].Enabled = false
+	node := n.innovations.split(g, split.Innovation)
+	g.Nodes = append(g.Nodes, nodeGene{ID: node, Kind: hiddenNode})
+	g.addConnection(connectionGene{In: split.In, Out: node, Weight: 1, Enabled: true, Innovation: n.innovations.connection(split.In, node)})
+	g.addConnection(connectionGene{In: node, Out: split.Out, Weight: split.Weight, Enabled: true, Innovation: n.innovations.connection(node, split.Out)})
+}
+
+func (g *genome) clone() *genome {
+	return &genome{
+		Nodes:       append([]nodeGene(nil), g.Nodes...),
+		Connections: append([]connectionGene(nil), g.Connections...),
+		Fitness:     g.Fitness,
+	}
+}
+
+func (g *genome) hasNode(id int) bool {
+	for _, node := range g.Nodes {
+		if node.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *genome) connected(from, to int) bool {
+	for _, c := range g.Connections {
+		if c.In == from && c.Out == to {
+			return true
+		}
+	}
+	return false
+}
+
+// reaches returns true if there is a path of connections, enabled or not, from node from to node to
+func (g *genome) reaches(from, to int) bool {
+	visited := map[int]bool{from: true}
+	stack := []int{from}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if node == to {
+			return true
+		}
+		for _, c := range g.Connections {
+			if c.In == node && !visited[c.Out] {
+				visited[c.Out] = true
+				stack = append(stack, c.Out)
+			}
+		}
+	}
+	return false
+}
+
+// addConnection inserts c keeping connections sorted by innovation
+func (g *genome) addConnection(c connectionGene) {
+	i := sort.Search(len(g.Connections), func(i int) bool { return g.Connections[i].Innovation > c.Innovation })
+	g.Connections = append(g.Connections, connectionGene{})
+	copy(g.Connections[i+1:], g.Connections[i:])
+	g.Connections[i] = c
+}
@@ -0,0 +1,501 @@
+package train
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"strings"
+
+	"github.com/renatobrittoaraujo/rl/input"
+	"github.com/renatobrittoaraujo/rl/nn"
+	"github.com/renatobrittoaraujo/rl/runs"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// PPOSettings holds the hyperparameters of PPO (Proximal Policy Optimization)
+type PPOSettings struct {
+	HiddenLayers         []int   // sizes of the hidden layers of both actor and critic
+	EpisodesPerIteration int     // episodes flown before every update
+	ActionRepeat         int     // ticks every sampled action is held for
+	Gamma                float64 // discount of future rewards, per sampled action
+	Lambda               float64 // GAE smoothing of advantages
+	ClipRange            float64 // how far the probability ratio of an action may move in one update
+	Epochs               int     // passes over every iteration's samples
+	MinibatchSize        int     // samples per gradient step
+	LearningRate         float64
+	EntropyCoefficient   float64 // weight of the entropy bonus, keeps the policy exploring
+	MaxGradientNorm      float64 // gradients are scaled down to this norm
+	InitialLogStd        float64 // log standard deviation of throttle and gimbal at the start
+	CheckpointEvery      int     // iterations between numbered model checkpoints, 0 disables them
+}
+
+// DefaultPPOSettings are the usual PPO hyperparameters for continuous control
+var DefaultPPOSettings = PPOSettings{
+	HiddenLayers:         []int{64, 64},
+	EpisodesPerIteration: 16,
+	ActionRepeat:         4,
+	Gamma:                0.99,
+	Lambda:               0.95,
+	ClipRange:            0.2,
+	Epochs:               10,
+	MinibatchSize:        256,
+	LearningRate:         3e-4,
+	EntropyCoefficient:   0.01,
+	MaxGradientNorm:      0.5,
+	InitialLogStd:        -0.5,
+	CheckpointEvery:      10,
+}
+
+// Outputs of the actor, the rcs logits are indexed by sim RCS constants
+const (
+	actorThrottle = iota
+	actorGimbal
+	actorRCS
+	actorOutputs = actorRCS + 3
+)
+
+// rcsExportGain turns the difference between rcs logits into an ai rcs output past the jet thresholds
+const rcsExportGain = 1e6
+
+// sample is a single decision of the policy during a rollout
+type sample struct {
+	input            []float64 // normalized observation
+	throttle, gimbal float64   // sampled before clamping
+	rcs              int
+	logProb          float64
+	value            float64
+	reward           float64
+	advantage        float64
+	ret              float64 // discounted return estimate, the critic's target
+}
+
+// ppoCheckpoint is the state of PPO between iterations (see checkpoint)
+type ppoCheckpoint struct {
+	Actor, Critic         *nn.Network
+	ActorAdam, CriticAdam *nn.Adam
+	LogStd                []float64
+	LogStdAdam            vectorAdam
+	Mean, Std             []float64
+}
+
+type ppo struct {
+	opts          Options
+	settings      PPOSettings
+	rng           *rand.Rand
+	source        *source
+	actor, critic *nn.Network
+	actorAdam     *nn.Adam
+	criticAdam    *nn.Adam
+	logStd        []float64 // of throttle and gimbal
+	logStdAdam    vectorAdam
+	mean, std     []float64 // observation normalization
+}
+
+func runPPO(opts Options) error {
+	s := opts.PPO
+	if s.EpisodesPerIteration < 1 || s.ActionRepeat < 1 || s.Epochs < 1 || s.MinibatchSize < 1 {
+		return fmt.Errorf("PPO needs at least 1 episode per iteration, action repeat, epoch and minibatch size")
+	}
+	p := &ppo{opts: opts, settings: s}
+	done := 0
+	if opts.Resume != "" {
+		var state ppoCheckpoint
+		var err error
+		if done, p.rng, p.source, err = loadCheckpoint(opts, &state); err != nil {
+			return err
+		}
+		p.actor, p.critic, p.actorAdam, p.criticAdam = state.Actor, state.Critic, state.ActorAdam, state.CriticAdam
+		p.logStd, p.logStdAdam, p.mean, p.std = state.LogStd, state.LogStdAdam, state.Mean, state.Std
+	} else {
+		p.rng, p.source = newRand(opts.Seed, 0)
+		p.logStd = []float64{s.InitialLogStd, s.InitialLogStd}
+		p.mean, p.std = observationStats(flightSeeds(p.rng, s.EpisodesPerIteration), opts)
+		inputs := len(input.ObservationLayout)
+		p.actor = nn.NewNetwork(layerSizes(inputs, s.HiddenLayers, actorOutputs), activations(len(s.HiddenLayers)), p.rng)
+		p.critic = nn.NewNetwork(layerSizes(inputs, s.HiddenLayers, 1), activations(len(s.HiddenLayers)), p.rng)
+		// A small last actor layer starts the policy close to its mean, with uniform rcs
+		p.actor.Layers[len(p.actor.Layers)-1].Scale(0.01)
+		p.actorAdam = nn.NewAdam(p.actor, s.LearningRate)
+		p.criticAdam = nn.NewAdam(p.critic, s.LearningRate)
+		p.logStdAdam = newVectorAdam(len(p.logStd), s.LearningRate)
+	}
+	rewards, err := openRewardLog(opts, done)
+	if err != nil {
+		return err
+	}
+	defer rewards.close()
+
+	for iteration := done + 1; iteration <= opts.Generations; iteration++ {
+		episodes, err := p.rollouts()
+		if err != nil {
+			return err
+		}
+		var samples []*sample
+		successes := 0
+		totalScore, totalReturn := 0.0, 0.0
+		landed := make([]bool, len(episodes))
+		terms := make([][rewardTerms]float64, len(episodes))
+		seeds := make([]int, len(episodes))
+		for e, episode := range episodes {
+			p.advantages(episode.samples)
+			for _, sample := range episode.samples {
+				samples = append(samples, sample)
+				totalReturn += sample.reward
+			}
+			totalScore += episode.score
+			if episode.success {
+				successes++
+			}
+			landed[e], terms[e], seeds[e] = episode.success, episode.rewards, episode.seed
+			if err := rewards.write(iteration, episode.seed, episode.score, episode.rewards); err != nil {
+				return err
+			}
+		}
+		normalizeAdvantages(samples)
+		p.update(samples)
+
+		opts.curriculum.record(landed)
+		fmt.Printf("ITERATION %v: samples %v mean score %.3f success %.1f%% mean return %.3f std %.3f %.3f%v\n",
+			iteration, len(samples), totalScore/float64(len(episodes)), 100*float64(successes)/float64(len(episodes)),
+			totalReturn/float64(len(episodes)), math.Exp(p.logStd[0]), math.Exp(p.logStd[1]), opts.curriculum.describe())
+		fmt.Println("REWARDS:", describeRewards(opts.Reward, terms))
+		err = logGeneration(opts, iteration, seeds, map[string]float64{
+			runs.MetricScore:   totalScore / float64(len(episodes)),
+			runs.MetricSuccess: float64(successes) / float64(len(episodes)),
+			"mean_return":      totalReturn / float64(len(episodes)),
+			"samples":          float64(len(samples)),
+			"std_throttle":     math.Exp(p.logStd[0]),
+			"std_gimbal":       math.Exp(p.logStd[1]),
+		})
+		if err != nil {
+			return err
+		}
+		opts.curriculum.advance()
+		if err := rewards.flush(); err != nil {
+			return err
+		}
+		model := p.model()
+		if err := model.Save(opts.ModelPath); err != nil {
+			return err
+		}
+		if s.CheckpointEvery > 0 && iteration%s.CheckpointEvery == 0 {
+			extension := filepath.Ext(opts.ModelPath)
+			path := fmt.Sprintf("%v-%v%v", strings.TrimSuffix(opts.ModelPath, extension), iteration, extension)
+			if opts.Run != nil {
+				path = opts.Run.Path(runs.CheckpointsDir, filepath.Base(path))
+			}
+			if err := model.Save(path); err != nil {
+				return err
+			}
+			fmt.Println("CHECKPOINT SAVED:", path)
+		}
+		if checkpointDue(opts, iteration) {
+			state := ppoCheckpoint{
+				Actor:      p.actor,
+				Critic:     p.critic,
+				ActorAdam:  p.actorAdam,
+				CriticAdam: p.criticAdam,
+				LogStd:     p.logStd,
+				LogStdAdam: p.logStdAdam,
+				Mean:       p.mean,
+				Std:        p.std,
+			}
+			if err := saveCheckpoint(opts, iteration, p.source, state); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Println("MODEL SAVED:", opts.ModelPath)
+	return nil
+}
+
+func layerSizes(inputs int, hidden []int, outputs int) []int {
+	return append(append([]int{inputs}, hidden...), outputs)
+}
+
+// activations returns tanh for every hidden layer and linear for the output layer
+func activations(hidden int) []string {
+	result := make([]string, hidden+1)
+	for i := range result {
+		result[i] = nn.Tanh
+	}
+	result[hidden] = nn.Linear
+	return result
+}
+
+// rollout is an episode flown by the policy
+type rollout struct {
+	seed    int
+	samples []*sample
+	score   float64 // terminalScore of the episode
+	success bool
+	rewards [rewardTerms]float64 // total of every reward term
+}
+
+// rollouts flies an iteration's episodes in parallel, each with its own random number generator
+// so samples do not depend on scheduling
+func (p *ppo) rollouts() ([]rollout, error) {
+	n := p.settings.EpisodesPerIteration
+	seeds, err := p.opts.curriculum.seeds(p.rng, n)
+	if err != nil {
+		return nil, err
+	}
+	rngSeeds := make([]int64, n)
+	for i := range rngSeeds {
+		rngSeeds[i] = p.rng.Int63()
+	}
+	episodes := make([]rollout, n)
+	parallel(n, p.opts.Workers, func(i int) {
+		episodes[i] = p.rollout(seeds[i], rand.New(rand.NewSource(rngSeeds[i])))
+	})
+	return episodes, nil
+}
+
+func (p *ppo) rollout(seed int, rng *rand.Rand) rollout {
+	episode := newEpisode(seed, p.opts)
+	var samples []*sample
+	done := false
+	for !done && episode.Rocket.IsAscending() {
+		_, done = episode.Step(falling{})
+	}
+	rewards := newRewardTracker(p.opts.Reward, episode.Rocket)
+	for !done {
+		s := &sample{input: p.normalize(input.ObservationVector(episode.Rocket.Observe()))}
+		out := p.actor.Forward(s.input)
+		s.throttle = out[actorThrottle] + math.Exp(p.logStd[0])*rng.NormFloat64()
+		s.gimbal = out[actorGimbal] + math.Exp(p.logStd[1])*rng.NormFloat64()
+		probabilities := softmax(out[actorRCS:])
+		s.rcs = len(probabilities) - 1
+		for r, threshold := 0, rng.Float64(); r < len(probabilities); r++ {
+			if threshold -= probabilities[r]; threshold < 0 {
+				s.rcs = r
+				break
+			}
+		}
+		s.logProb = p.logProb(out, s)
+		s.value = p.critic.Forward(s.input)[0]
+
+		action := sim.Action{
+			EngineOn: s.throttle > 0,
+			Thrust:   float32(s.throttle),
+			Gimbal:   float32(s.gimbal),
+			RCS:      s.rcs,
+		}
+		for tick := 0; tick < p.settings.ActionRepeat && !done; tick++ {
+			_, done = episode.Step(heldAction(action))
+		}
+		s.reward = rewards.step(episode, done, p.settings.Gamma)
+		samples = append(samples, s)
+	}
+	return rollout{
+		seed:    seed,
+		samples: samples,
+		score:   terminalScore(episode),
+		success: episode.Outcome == sim.OutcomeSuccess,
+		rewards: rewards.totals,
+	}
+}
+
+// heldAction always acts the same
+type heldAction sim.Action
+
+func (h heldAction) Act(sim.Observation) sim.Action {
+	return sim.Action(h)
+}
+
+func (p *ppo) normalize(observation []float64) []float64 {
+	for i := range observation {
+		observation[i] = (observation[i] - p.mean[i]) / p.std[i]
+	}
+	return observation
+}
+
+// logProb returns the log probability of the actions of s under the policy whose actor output is out
+func (p *ppo) logProb(out []float64, s *sample) float64 {
+	return gaussianLogProb(s.throttle, out[actorThrottle], p.logStd[0]) +
+		gaussianLogProb(s.gimbal, out[actorGimbal], p.logStd[1]) +
+		math.Log(softmax(out[actorRCS:])[s.rcs])
+}
+
+func gaussianLogProb(x, mean, logStd float64) float64 {
+	z := (x - mean) / math.Exp(logStd)
+	return -0.5*z*z - logStd - 0.5*math.Log(2*math.Pi)
+}
+
+func softmax(logits []float64) []float64 {
+	max := logits[0]
+	for _, l := range logits {
+		max = math.Max(max, l)
+	}
+	result := make([]float64, len(logits))
+	sum := 0.0
+	for i, l := range logits {
+		result[i] = math.Exp(l - max)
+		sum += result[i]
+	}
+	for i := range result {
+		result[i] /= sum
+	}
+	return result
+}
+
+// advantages sets the GAE advantage and return of every sample of an episode
+func (p *ppo) advantages(episode []*sample) {
+	advantage, nextValue := 0.0, 0.0
+	for i := len(episode) - 1; i >= 0; i-- {
+		s := episode[i]
+		delta := s.reward + p.settings.Gamma*nextValue - s.value
+		advantage = delta + p.settings.Gamma*p.settings.Lambda*advantage
+		s.advantage = advantage
+		s.ret = advantage + s.value
+		nextValue = s.value
+	}
+}
+
+func normalizeAdvantages(samples []*sample) {
+	mean, squares := 0.0, 0.0
+	for _, s := range samples {
+		mean += s.advantage
+		squares += s.advantage * s.advantage
+	}
+	mean /= float64(len(samples))
+	std := math.Sqrt(math.Max(squares/float64(len(samples))-mean*mean, 0)) + 1e-8
+	for _, s := range samples {
+		s.advantage = (s.advantage - mean) / std
+	}
+}
+
+// update runs the clipped PPO objective over samples for every epoch, in shuffled minibatches
+func (p *ppo) update(samples []*sample) {
+	actorGrads := nn.NewGradients(p.actor)
+	criticGrads := nn.NewGradients(p.critic)
+	logStdGrads := make([]float64, len(p.logStd))
+	for epoch := 0; epoch < p.settings.Epochs; epoch++ {
+		order := p.rng.Perm(len(samples))
+		for start := 0; start < len(order); start += p.settings.MinibatchSize {
+			end := start + p.settings.MinibatchSize
+			if end > len(order) {
+				end = len(order)
+			}
+			actorGrads.Zero()
+			criticGrads.Zero()
+			for i := range logStdGrads {
+				logStdGrads[i] = 0
+			}
+			batch := float64(end - start)
+			for _, index := range order[start:end] {
+				p.accumulate(samples[index], actorGrads, criticGrads, logStdGrads, batch)
+			}
+			clipGradients(actorGrads, p.settings.MaxGradientNorm)
+			clipGradients(criticGrads, p.settings.MaxGradientNorm)
+			p.actorAdam.Update(p.actor, actorGrads)
+			p.criticAdam.Update(p.critic, criticGrads)
+			p.logStdAdam.update(p.logStd, logStdGrads)
+		}
+	}
+}
+
+// accumulate adds the gradients of the loss of s, averaged over a minibatch of size batch
+func (p *ppo) accumulate(s *sample, actorGrads, criticGrads *nn.Gradients, logStdGrads []float64, batch float64) {
+	out, trace := p.actor.ForwardTrace(s.input)
+	ratio := math.Exp(p.logProb(out, s) - s.logProb)
+	// The clipped objective has no gradient once the ratio moved past the clip range in the advantage's direction
+	coefficient := 0.0
+	if (s.advantage >= 0 && ratio < 1+p.settings.ClipRange) || (s.advantage < 0 && ratio > 1-p.settings.ClipRange) {
+		coefficient = -s.advantage * ratio / batch
+	}
+	entropy := p.settings.EntropyCoefficient / batch
+
+	gradient := make([]float64, actorOutputs)
+	for i, action := range []float64{s.throttle, s.gimbal} {
+		mean := out[actorThrottle+i]
+		variance := math.Exp(2 * p.logStd[i])
+		gradient[actorThrottle+i] = coefficient * (action - mean) / variance
+		logStdGrads[i] += coefficient*((action-mean)*(action-mean)/variance-1) - entropy
+	}
+	probabilities := softmax(out[actorRCS:])
+	h := 0.0
+	for _, probability := range probabilities {
+		h -= probability * math.Log(probability)
+	}
+	for r, probability := range probabilities {
+		chosen := 0.0
+		if r == s.rcs {
+			chosen = 1
+		}
+		gradient[actorRCS+r] = coefficient*(chosen-probability) + entropy*probability*(math.Log(probability)+h)
+	}
+	p.actor.Backward(trace, gradient, actorGrads)
+
+	value, trace := p.critic.ForwardTrace(s.input)
+	p.critic.Backward(trace, []float64{(value[0] - s.ret) / batch}, criticGrads)
+}
+
+func clipGradients(grads *nn.Gradients, maxNorm float64) {
+	if norm := grads.Norm(); maxNorm > 0 && norm > maxNorm {
+		grads.Scale(maxNorm / norm)
+	}
+}
+
+// model returns the policy's most likely actions as a model executable by the ai input
+//
+// Two layers are added after the actor: the first computes how much the right and left
+// rcs logits exceed the none logit, the second maps throttle, gimbal and those excesses
+// to the ai outputs, so the ai fires the jet with the highest logit
+func (p *ppo) model() *nn.Model {
+	network := nn.Network{}
+	for _, layer := range p.actor.Layers {
+		network.Layers = append(network.Layers, layer.Clone())
+	}
+	excess := nn.Layer{
+		Activation:  nn.Linear,
+		Activations: []string{nn.Linear, nn.ReLU, nn.ReLU, nn.Linear},
+		Weights: [][]float64{
+			{1, 0, 0, 0, 0},
+			{0, 0, -1, 0, 1},
+			{0, 0, -1, 1, 0},
+			{0, 1, 0, 0, 0},
+		},
+		Biases: make([]float64, 4),
+	}
+	// Rows are in input.ActionLayout order: engine, thrust, rcs and gimbal
+	output := nn.Layer{
+		Activation: nn.Linear,
+		Weights: [][]float64{
+			{1, 0, 0, 0},
+			{2, 0, 0, 0},
+			{0, rcsExportGain, -rcsExportGain, 0},
+			{0, 0, 0, 1},
+		},
+		Biases: []float64{0, -1, 0, 0},
+	}
+	network.Layers = append(network.Layers, excess, output)
+	model := nn.NewModel(network, input.ObservationLayout, input.ActionLayout)
+	model.InputMean = p.mean
+	model.InputStd = p.std
+	return model
+}
+
+// vectorAdam is Adam for a plain vector of parameters
+type vectorAdam struct {
+	LearningRate float64
+	Steps        int
+	M, V         []float64
+}
+
+func newVectorAdam(size int, learningRate float64) vectorAdam {
+	return vectorAdam{LearningRate: learningRate, M: make([]float64, size), V: make([]float64, size)}
+}
+
+func (a *vectorAdam) update(params, grads []float64) {
+	const beta1, beta2, epsilon = 0.9, 0.999, 1e-8
+	a.Steps++
+	for i, g := range grads {
+		a.M[i] = beta1*a.M[i] + (1-beta1)*g
+		a.V[i] = beta2*a.V[i] + (1-beta2)*g*g
+		m := a.M[i] / (1 - math.Pow(beta1, float64(a.Steps)))
+		v := a.V[i] / (1 - math.Pow(beta2, float64(a.Steps)))
+		params[i] -= a.LearningRate * m / (math.Sqrt(v) + epsilon)
+	}
+}
@@ -0,0 +1,149 @@
+package train
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/renatobrittoaraujo/rl/input"
+	"github.com/renatobrittoaraujo/rl/runs"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// QLearningSettings holds the hyperparameters of tabular Q-learning
+type QLearningSettings struct {
+	LearningRate     float64 // how far a value moves towards its target on every update
+	Gamma            float64 // discount of future rewards, per decision
+	EpsilonStart     float64 // chance of a random action in the first generation
+	EpsilonEnd       float64 // chance of a random action once decayed
+	EpsilonDecay     int     // generations epsilon takes to fall linearly from EpsilonStart to EpsilonEnd
+	ThrottleStep     float32 // thrust added or removed by throttle up and down
+	TicksPerDecision int     // ticks every decision is held for
+}
+
+// DefaultQLearningSettings decay exploration over half of the default generations
+var DefaultQLearningSettings = QLearningSettings{
+	LearningRate:     0.1,
+	Gamma:            0.99,
+	EpsilonStart:     1,
+	EpsilonEnd:       0.05,
+	EpsilonDecay:     50,
+	ThrottleStep:     0.1,
+	TicksPerDecision: 6,
+}
+
+func runQLearning(opts Options) error {
+	s := opts.QLearning
+	if s.TicksPerDecision < 1 || opts.SeedsPerEvaluation < 1 {
+		return fmt.Errorf("Q-learning needs at least 1 tick per decision and seed per evaluation")
+	}
+	var rng *rand.Rand
+	var source *source
+	var table *input.QTable
+	done := 0
+	if opts.Resume != "" {
+		var err error
+		if done, rng, source, err = loadCheckpoint(opts, &table); err != nil {
+			return err
+		}
+	} else {
+		rng, source = newRand(opts.Seed, 0)
+		table = input.NewQTable(s.ThrottleStep, s.TicksPerDecision)
+	}
+	rewards, err := openRewardLog(opts, done)
+	if err != nil {
+		return err
+	}
+	defer rewards.close()
+	for generation := done + 1; generation <= opts.Generations; generation++ {
+		epsilon := s.EpsilonEnd
+		if generation <= s.EpsilonDecay {
+			epsilon = s.EpsilonStart + (s.EpsilonEnd-s.EpsilonStart)*float64(generation-1)/float64(s.EpsilonDecay)
+		}
+		total, successes := 0.0, 0
+		landed := make([]bool, 0, opts.SeedsPerEvaluation)
+		terms := make([][rewardTerms]float64, 0, opts.SeedsPerEvaluation)
+		seeds, err := opts.curriculum.seeds(rng, opts.SeedsPerEvaluation)
+		if err != nil {
+			return err
+		}
+		for _, seed := range seeds {
+			score, success, totals := qLearningEpisode(table, seed, epsilon, rng, opts)
+			landed = append(landed, success)
+			terms = append(terms, totals)
+			if err := rewards.write(generation, seed, score, totals); err != nil {
+				return err
+			}
+			total += score
+			if success {
+				successes++
+			}
+		}
+		visited := 0
+		for _, values := range table.Values {
+			for _, value := range values {
+				if value != 0 {
+					visited++
+					break
+				}
+			}
+		}
+		opts.curriculum.record(landed)
+		fmt.Printf("GENERATION %v: epsilon %.3f mean score %.3f success %.1f%% states visited %v/%v%v\n",
+			generation, epsilon, total/float64(opts.SeedsPerEvaluation), 100*float64(successes)/float64(opts.SeedsPerEvaluation), visited, len(table.Values), opts.curriculum.describe())
+		fmt.Println("REWARDS:", describeRewards(opts.Reward, terms))
+		err = logGeneration(opts, generation, seeds, map[string]float64{
+			runs.MetricScore:   total / float64(opts.SeedsPerEvaluation),
+			runs.MetricSuccess: float64(successes) / float64(opts.SeedsPerEvaluation),
+			"epsilon":          epsilon,
+			"states_visited":   float64(visited),
+		})
+		if err != nil {
+			return err
+		}
+		opts.curriculum.advance()
+		if err := rewards.flush(); err != nil {
+			return err
+		}
+		if err := table.Save(opts.ModelPath); err != nil {
+			return err
+		}
+		if checkpointDue(opts, generation) {
+			if err := saveCheckpoint(opts, generation, source, table); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Println("Q-TABLE SAVED:", opts.ModelPath)
+	return nil
+}
+
+// qLearningEpisode flies seed taking epsilon-greedy decisions and updating table after each one,
+// returning the final score, whether it was a success and the total of every reward term
+func qLearningEpisode(table *input.QTable, seed int, epsilon float64, rng *rand.Rand, opts Options) (float64, bool, [rewardTerms]float64) {
+	s := opts.QLearning
+	episode := newEpisode(seed, opts)
+	done := false
+	for !done && episode.Rocket.IsAscending() {
+		_, done = episode.Step(falling{})
+	}
+	rewards := newRewardTracker(opts.Reward, episode.Rocket)
+	for !done {
+		obs := episode.Rocket.Observe()
+		state := table.State(obs)
+		action := table.Best(state)
+		if rng.Float64() < epsilon {
+			action = rng.Intn(input.QActions)
+		}
+		decision := table.Decide(obs, action)
+		for tick := 0; tick < s.TicksPerDecision && !done; tick++ {
+			_, done = episode.Step(heldAction(decision))
+		}
+		target := rewards.step(episode, done, s.Gamma)
+		if !done {
+			next := table.State(episode.Rocket.Observe())
+			target += s.Gamma * table.Values[next][table.Best(next)]
+		}
+		table.Values[state][action] += s.LearningRate * (target - table.Values[state][action])
+	}
+	return terminalScore(episode), episode.Outcome == sim.OutcomeSuccess, rewards.totals
+}
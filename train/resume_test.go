@@ -0,0 +1,93 @@
+package train
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/renatobrittoaraujo/rl/input"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// TestResume checks that every trainer interrupted halfway and resumed from its checkpoint
+// ends in exactly the same state as when it trains without stopping
+func TestResume(t *testing.T) {
+	manager, inputErr := input.CreateInput(input.AutopilotInput)
+	if inputErr {
+		t.Fatal("autopilot input has not been initalized correctly")
+	}
+	// Untrained controllers fly for long, flights are cut short to keep the test fast
+	limits := sim.DefaultLimits
+	limits.MaxFlightTime = 60
+
+	generations := func(opts *Options, n int) { opts.Generations = n }
+	tests := []struct {
+		trainer   string
+		extension string
+		length    func(opts *Options, n int) // sets how many generations opts trains for
+	}{
+		{TrainerNEAT, ".rlnn", generations},
+		{TrainerCMAES, ".json", generations},
+		{TrainerPPO, ".rlnn", generations},
+		{TrainerQLearning, ".json", generations},
+		// Imitation trains for Generations epochs every DAgger round, its rounds are its generations
+		{TrainerImitation, ".rlnn", func(opts *Options, n int) { opts.Imitation.DAggerIterations = n }},
+	}
+	for _, test := range tests {
+		t.Run(test.trainer, func(t *testing.T) {
+			options := func(dir string, n int) Options {
+				opts := Options{
+					Trainer:            test.trainer,
+					Generations:        1,
+					Population:         6,
+					Workers:            2,
+					SeedsPerEvaluation: 2,
+					Seed:               1,
+					Level:              sim.LevelFree,
+					Limits:             limits,
+					ModelPath:          filepath.Join(dir, "model"+test.extension),
+					CheckpointEvery:    1,
+					Tunable:            manager.(input.Tunable),
+					NEAT:               DefaultNEATSettings,
+					CMAES:              DefaultCMAESSettings,
+					PPO:                DefaultPPOSettings,
+					QLearning:          DefaultQLearningSettings,
+					Imitation:          DefaultImitationSettings,
+					Reward:             DefaultRewardSettings,
+				}
+				opts.CMAES.PopulationSize = 4
+				opts.PPO.HiddenLayers, opts.PPO.EpisodesPerIteration, opts.PPO.Epochs, opts.PPO.MinibatchSize = []int{8}, 2, 1, 64
+				opts.Imitation.HiddenLayers, opts.Imitation.DAggerEpisodes, opts.Imitation.MinibatchSize = []int{8}, 2, 64
+				test.length(&opts, n)
+				return opts
+			}
+
+			whole := options(t.TempDir(), 4)
+			if err := Run(whole); err != nil {
+				t.Fatal(err)
+			}
+			halves := options(t.TempDir(), 2)
+			if err := Run(halves); err != nil {
+				t.Fatal(err)
+			}
+			halves = options(filepath.Dir(halves.ModelPath), 4)
+			halves.Resume = checkpointPath(halves)
+			if err := Run(halves); err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := ioutil.ReadFile(checkpointPath(whole))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(checkpointPath(halves))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Error("checkpoint after resuming differs from the one of training without stopping")
+			}
+		})
+	}
+}
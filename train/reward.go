@@ -0,0 +1,219 @@
+package train
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// rewardDistanceScale is how many meters from the pad lose a whole Distance of potential
+const rewardDistanceScale = 1000
+
+// RewardSettings weigh every term of the reward of each decision of PPO and Q-learning, 0 disables a term
+//
+// Velocity, Attitude and Distance shape rewards with potentials: a decision earns the discounted
+// potential it reached minus the one it started from, and a finished episode has no potential left
+type RewardSettings struct {
+	Velocity  float64 // potential lost per MaxLandingVelocity of speed
+	Attitude  float64 // potential lost per MaxAngleDeviation away from upright
+	Distance  float64 // potential lost per rewardDistanceScale meters from the center of the pad on the ground
+	Fuel      float64 // cost of burning a whole tank
+	Ignitions float64 // cost of every ignition
+	Contact   float64 // bonus for touching the ground, however the landing went
+	Terminal  float64 // weight of the score of the finished episode, sim.FlightScore
+}
+
+// DefaultRewardSettings shape rewards by speed and attitude and end them with the landing score
+var DefaultRewardSettings = RewardSettings{Velocity: 1, Attitude: 1, Terminal: 1}
+
+// Reward terms, in the order of rewardTracker.totals
+const (
+	rewardVelocity = iota
+	rewardAttitude
+	rewardDistance
+	rewardFuel
+	rewardIgnitions
+	rewardContact
+	rewardTerminal
+	rewardTerms
+)
+
+var rewardTermNames = [rewardTerms]string{"Velocity", "Attitude", "Distance", "Fuel", "Ignitions", "Contact", "Terminal"}
+
+// rewardTracker computes the reward of every decision of an episode, keeping the total of each term
+type rewardTracker struct {
+	settings   RewardSettings
+	potentials [rewardDistance + 1]float64 // weighted potentials of the rocket at the last decision
+	fuel       float32
+	engineOn   bool
+	totals     [rewardTerms]float64
+}
+
+// newRewardTracker starts tracking the rewards of an episode whose rocket is r, right after ascension
+func newRewardTracker(settings RewardSettings, r *sim.Rocket) *rewardTracker {
+	t := &rewardTracker{settings: settings}
+	t.potentials = t.potentialsOf(r)
+	t.fuel, t.engineOn = r.FuelPercentage(), r.Observe().EngineOn
+	return t
+}
+
+// potentialsOf returns every weighted potential of r, all of them higher the closer r is to landing
+func (t *rewardTracker) potentialsOf(r *sim.Rocket) [rewardDistance + 1]float64 {
+	s := t.settings
+	angle := math.Abs(math.Pi/2 - float64(r.Direction))
+	pad := sim.Pad{}
+	if r.LandingPad != nil {
+		pad = *r.LandingPad
+	}
+	distance := math.Hypot(float64(r.Position.X)-float64(pad.MinX+pad.MaxX)/2, float64(r.Position.Y)-sim.RocketLenght/2)
+	return [...]float64{
+		-s.Velocity * float64(r.Velocity()) / sim.MaxLandingVelocity,
+		-s.Attitude * angle / sim.MaxAngleDeviation,
+		-s.Distance * distance / rewardDistanceScale,
+	}
+}
+
+// step returns the reward of the decision that just ended, done if it ended the episode, with potentials
+// discounted by the gamma of the trainer
+func (t *rewardTracker) step(episode *sim.Episode, done bool, gamma float64) float64 {
+	r := episode.Rocket
+	var after [rewardDistance + 1]float64
+	if !done {
+		after = t.potentialsOf(r)
+	}
+	reward := gamma*sum(after[:]) - sum(t.potentials[:])
+	for term := range after {
+		t.totals[term] += gamma*after[term] - t.potentials[term]
+	}
+	var terms [rewardTerms]float64
+	if done {
+		terms[rewardTerminal] = t.settings.Terminal * terminalScore(episode)
+		if episode.Outcome.Landed() {
+			terms[rewardContact] = t.settings.Contact
+		}
+	}
+	terms[rewardFuel] = -t.settings.Fuel * float64(t.fuel-r.FuelPercentage())
+	engineOn := r.Observe().EngineOn
+	if engineOn && !t.engineOn {
+		terms[rewardIgnitions] = -t.settings.Ignitions
+	}
+	for term := rewardFuel; term < rewardTerms; term++ {
+		reward += terms[term]
+		t.totals[term] += terms[term]
+	}
+	t.potentials, t.fuel, t.engineOn = after, r.FuelPercentage(), engineOn
+	return reward
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return total
+}
+
+// terminalScore is the sim.FlightScore of a finished episode
+func terminalScore(episode *sim.Episode) float64 {
+	return float64(sim.FlightScore(episode.Rocket, episode.Outcome))
+}
+
+// rewardLog writes the reward terms of every episode as JSONL, to debug shaping
+type rewardLog struct {
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+// rewardEntry is a line of the reward log
+type rewardEntry struct {
+	Generation int
+	Seed       int
+	Score      float64 // terminalScore of the episode
+	Rewards    map[string]float64
+}
+
+// rewardLogPath is where the reward log is written, in the run directory or next to opts.ModelPath without a run
+func rewardLogPath(opts Options) string {
+	if opts.Run != nil {
+		return opts.Run.Path("rewards.jsonl")
+	}
+	return opts.ModelPath + ".rewards.jsonl"
+}
+
+// openRewardLog creates the reward log, keeping the entries of the first resumed generations of an existing one
+func openRewardLog(opts Options, resumed int) (*rewardLog, error) {
+	path := rewardLogPath(opts)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	var kept []byte
+	if resumed > 0 {
+		data, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, line := range strings.SplitAfter(string(data), "\n") {
+			var entry rewardEntry
+			if json.Unmarshal([]byte(line), &entry) == nil && entry.Generation <= resumed {
+				kept = append(kept, line...)
+			}
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	writer := bufio.NewWriter(file)
+	if _, err := writer.Write(kept); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &rewardLog{file: file, writer: writer, encoder: json.NewEncoder(writer)}, nil
+}
+
+// write logs an episode's total of every reward term
+func (l *rewardLog) write(generation, seed int, score float64, totals [rewardTerms]float64) error {
+	entry := rewardEntry{Generation: generation, Seed: seed, Score: score, Rewards: map[string]float64{}}
+	for term, total := range totals {
+		entry.Rewards[rewardTermNames[term]] = total
+	}
+	return l.encoder.Encode(entry)
+}
+
+// flush writes what was logged so far to the file
+func (l *rewardLog) flush() error {
+	return l.writer.Flush()
+}
+
+func (l *rewardLog) close() error {
+	if err := l.writer.Flush(); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+// describeRewards returns the mean of every enabled reward term over episodes, for a generation's log
+func describeRewards(settings RewardSettings, episodes [][rewardTerms]float64) string {
+	weights := [rewardTerms]float64{settings.Velocity, settings.Attitude, settings.Distance, settings.Fuel, settings.Ignitions, settings.Contact, settings.Terminal}
+	var parts []string
+	for term, weight := range weights {
+		if weight == 0 || len(episodes) == 0 {
+			continue
+		}
+		mean := 0.0
+		for _, totals := range episodes {
+			mean += totals[term]
+		}
+		parts = append(parts, fmt.Sprintf("%v %.3f", strings.ToLower(rewardTermNames[term]), mean/float64(len(episodes))))
+	}
+	return strings.Join(parts, " ")
+}
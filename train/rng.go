@@ -0,0 +1,30 @@
+package train
+
+import "math/rand"
+
+// source is a math/rand source that counts its draws, so its state can be
+// checkpointed as a seed and a count and restored by drawing as many times again
+type source struct {
+	rand.Source64
+	seed  int64
+	draws uint64
+}
+
+// newRand returns a generator drawing from a new source, with state already advanced by draws
+func newRand(seed int64, draws uint64) (*rand.Rand, *source) {
+	s := &source{Source64: rand.NewSource(seed).(rand.Source64), seed: seed}
+	for s.draws < draws {
+		s.Int63()
+	}
+	return rand.New(s), s
+}
+
+func (s *source) Int63() int64 {
+	s.draws++
+	return s.Source64.Int63()
+}
+
+func (s *source) Uint64() uint64 {
+	s.draws++
+	return s.Source64.Uint64()
+}
@@ -0,0 +1,211 @@
+package train
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+
+	"github.com/renatobrittoaraujo/rl/input"
+	"github.com/renatobrittoaraujo/rl/runs"
+	"github.com/renatobrittoaraujo/rl/sim"
+)
+
+// Names of every trainer
+const (
+	// TrainerNEAT evolves network weights and topology (see NEATSettings)
+	TrainerNEAT = "neat"
+	// TrainerCMAES tunes the parameters of any input.Tunable controller (see CMAESSettings)
+	TrainerCMAES = "cmaes"
+	// TrainerPPO trains an actor-critic network with gradients (see PPOSettings)
+	TrainerPPO = "ppo"
+	// TrainerQLearning fills the Q-table of the qlearning input (see QLearningSettings)
+	TrainerQLearning = "qlearning"
+	// TrainerImitation clones recorded flights and the autopilot into a network (see ImitationSettings)
+	TrainerImitation = "imitation"
+)
+
+// Trainers are the names accepted by Options.Trainer
+var Trainers = []string{TrainerNEAT, TrainerCMAES, TrainerPPO, TrainerQLearning, TrainerImitation}
+
+// maxSeed is the largest seed flown during training
+const maxSeed = 100000
+
+// Options configure a training run
+type Options struct {
+	Trainer            string            // one of Trainers
+	Generations        int               // generations (or iterations) to train for
+	Population         int               // individuals per generation
+	Workers            int               // episodes simulated in parallel, 0 uses every CPU
+	SeedsPerEvaluation int               // episodes flown to evaluate an individual
+	Seed               int64             // seed of the trainer's random number generator
+	Level              int               // sim package level flown
+	Limits             sim.Limits        // limits of every flight
+	ModelPath          string            // the best model found is saved here, as it improves
+	Resume             string            // checkpoint to continue training from, if any
+	CheckpointEvery    int               // generations between checkpoints of the whole training state, 0 disables them
+	Run                *runs.Run         // records seeds, metrics and checkpoints of every generation, nil keeps them next to ModelPath
+	Tunable            input.Tunable     // controller tuned by TrainerCMAES
+	NEAT               NEATSettings      // hyperparameters of TrainerNEAT
+	CMAES              CMAESSettings     // hyperparameters of TrainerCMAES
+	PPO                PPOSettings       // hyperparameters of TrainerPPO
+	QLearning          QLearningSettings // hyperparameters of TrainerQLearning
+	Imitation          ImitationSettings // hyperparameters of TrainerImitation
+	Curriculum         CurriculumSettings
+	Reward             RewardSettings // reward terms of TrainerPPO and TrainerQLearning
+	curriculum         *curriculum    // current stage, nil without a curriculum
+}
+
+// Run trains a controller as configured by opts
+func Run(opts Options) error {
+	opts.curriculum = newCurriculum(opts.Curriculum)
+	switch opts.Trainer {
+	case TrainerNEAT:
+		return runNEAT(opts)
+	case TrainerCMAES:
+		return runCMAES(opts)
+	case TrainerPPO:
+		return runPPO(opts)
+	case TrainerQLearning:
+		return runQLearning(opts)
+	case TrainerImitation:
+		return runImitation(opts)
+	}
+	return fmt.Errorf("unknown trainer %q", opts.Trainer)
+}
+
+// logGeneration records the seeds flown by a generation, if any, and its metrics to opts.Run
+func logGeneration(opts Options, generation int, seeds []int, values map[string]float64) error {
+	if seeds != nil {
+		if err := opts.Run.Flew(seeds); err != nil {
+			return err
+		}
+	}
+	metrics := runs.Metrics{Generation: generation, Values: values}
+	if c := opts.curriculum; c != nil {
+		metrics.Stage = c.settings.Stages[c.stage].Name
+		values["rolling_success"] = c.successRate()
+	}
+	return opts.Run.Generation(metrics)
+}
+
+// flightSeeds returns n seeds for flights, drawn from rng
+func flightSeeds(rng *rand.Rand, n int) []int {
+	seeds := make([]int, n)
+	for i := range seeds {
+		seeds[i] = rng.Intn(maxSeed) + 1
+	}
+	return seeds
+}
+
+// flightScore flies seed with controller and returns its sim.FlightScore
+func flightScore(controller sim.Controller, seed int, opts Options) float64 {
+	episode := newEpisode(seed, opts)
+	episode.Run(controller)
+	return float64(sim.FlightScore(episode.Rocket, episode.Outcome))
+}
+
+// meanScore is the fitness of a controller, its mean flightScore over seeds
+func meanScore(controller sim.Controller, seeds []int, opts Options) float64 {
+	total := 0.0
+	for _, seed := range seeds {
+		total += flightScore(controller, seed, opts)
+	}
+	return total / float64(len(seeds))
+}
+
+// flightSuccesses returns whether controller landed successfully on every seed
+func flightSuccesses(controller sim.Controller, seeds []int, opts Options) []bool {
+	successes := make([]bool, len(seeds))
+	for i, seed := range seeds {
+		episode := newEpisode(seed, opts)
+		episode.Run(controller)
+		successes[i] = episode.Outcome == sim.OutcomeSuccess
+	}
+	return successes
+}
+
+// successRate returns the fraction of successes that are true
+func successRate(successes []bool) float64 {
+	count := 0
+	for _, success := range successes {
+		if success {
+			count++
+		}
+	}
+	return float64(count) / float64(len(successes))
+}
+
+// parallel calls fn for every i in [0, n) on opts.Workers goroutines
+//
+// fn must only write to state owned by i, so results do not depend on scheduling
+func parallel(n int, workers int, fn func(i int)) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// falling never touches the engine, so the rocket falls from wherever ascension left it
+type falling struct{}
+
+func (falling) Act(sim.Observation) sim.Action {
+	return sim.Action{}
+}
+
+// observationStats returns the mean and standard deviation of every value of input.ObservationVector
+// over flights of seeds where the rocket falls after ascension, used to normalize network inputs
+//
+// Flights are at opts.Level without disturbances even with a curriculum, inputs of every stage are normalized alike
+func observationStats(seeds []int, opts Options) (mean, std []float64) {
+	size := len(input.ObservationLayout)
+	sum := make([]float64, size)
+	squares := make([]float64, size)
+	samples := 0
+	for _, seed := range seeds {
+		episode := sim.NewEpisode(seed, opts.Level)
+		episode.Limits = opts.Limits
+		for {
+			_, done := episode.Step(falling{})
+			if !episode.Rocket.IsAscending() {
+				for i, value := range input.ObservationVector(episode.Rocket.Observe()) {
+					sum[i] += value
+					squares[i] += value * value
+				}
+				samples++
+			}
+			if done {
+				break
+			}
+		}
+	}
+	mean = make([]float64, size)
+	std = make([]float64, size)
+	for i := range mean {
+		std[i] = 1
+		if samples == 0 {
+			continue
+		}
+		mean[i] = sum[i] / float64(samples)
+		if variance := squares[i]/float64(samples) - mean[i]*mean[i]; variance > 1e-6 {
+			std[i] = math.Sqrt(variance)
+		}
+	}
+	return mean, std
+}